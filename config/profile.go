@@ -0,0 +1,163 @@
+package config
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ProfileFile is the filename of the hardware profile.
+const ProfileFile = "/etc/neco/hw-profile.yml"
+
+// ProfileVersion is the version of the hardware profile format supported.
+const ProfileVersion = 1
+
+//go:embed default-hw-profile.yml
+var defaultProfile []byte
+
+// Profile represents the desired BIOS and BMC settings in YAML format.
+type Profile struct {
+	Version  int        `json:"version"`
+	Settings []*Setting `json:"settings"`
+}
+
+// Setting is a desired value of a BIOS or BMC setting.
+type Setting struct {
+	// Key is the name of the setting, e.g. "BIOS.ProcSettings.LogicalProc".
+	Key string `json:"key"`
+
+	// Value is the desired value as read from the BMC.
+	Value string `json:"value"`
+
+	// SetValue is the value to be written if it differs from Value, e.g. "1" for "Enabled".
+	// If empty, Value is written.
+	SetValue string `json:"setValue,omitempty"`
+
+	// When is the condition to apply this setting.  If nil, the setting is always applied.
+	When *Condition `json:"when,omitempty"`
+}
+
+// Condition is a condition of a setting.  All the non-empty fields must be satisfied.
+type Condition struct {
+	// Model is a regular expression matched against the model name of the server.
+	Model string `json:"model,omitempty"`
+
+	// TPMVersion is the version of TPM, "1.2" or "2.0".
+	TPMVersion string `json:"tpmVersion,omitempty"`
+
+	// Key is the name of another setting whose current value is compared with Equals or NotEquals.
+	Key       string `json:"key,omitempty"`
+	Equals    string `json:"equals,omitempty"`
+	NotEquals string `json:"notEquals,omitempty"`
+
+	model *regexp.Regexp
+}
+
+// Facts are the properties of a server used to evaluate conditions.
+type Facts struct {
+	Model      string
+	TPMVersion string
+}
+
+// DefaultProfile returns the built-in hardware profile.
+func DefaultProfile() *Profile {
+	p, err := ParseProfile(defaultProfile)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// LoadProfile loads the hardware profile from filename.
+// If the file does not exist, it returns the built-in profile.
+func LoadProfile(filename string) (*Profile, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return DefaultProfile(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseProfile(data)
+}
+
+// ParseProfile parses and validates a hardware profile.
+func ParseProfile(data []byte) (*Profile, error) {
+	p := new(Profile)
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate validates the hardware profile, and compiles its conditions.
+func (p *Profile) Validate() error {
+	if p.Version != ProfileVersion {
+		return fmt.Errorf("unsupported profile version: %d", p.Version)
+	}
+
+	for _, s := range p.Settings {
+		if s.Key == "" {
+			return errors.New("key is empty")
+		}
+		if s.When == nil {
+			continue
+		}
+
+		c := s.When
+		if c.Model != "" {
+			r, err := regexp.Compile(c.Model)
+			if err != nil {
+				return fmt.Errorf("invalid model pattern for %s: %w", s.Key, err)
+			}
+			c.model = r
+		}
+		if c.TPMVersion != "" && c.TPMVersion != "1.2" && c.TPMVersion != "2.0" {
+			return fmt.Errorf("invalid TPM version for %s: %s", s.Key, c.TPMVersion)
+		}
+		if c.Key == "" && (c.Equals != "" || c.NotEquals != "") {
+			return fmt.Errorf("equals and notEquals need key for %s", s.Key)
+		}
+		if c.Key != "" && c.Equals == "" && c.NotEquals == "" {
+			return fmt.Errorf("key needs equals or notEquals for %s", s.Key)
+		}
+	}
+	return nil
+}
+
+// Match returns whether the condition is satisfied.
+// get is called to read the current value of Key.
+// A nil condition is always satisfied.
+func (c *Condition) Match(facts Facts, get func(key string) (string, error)) (bool, error) {
+	if c == nil {
+		return true, nil
+	}
+	if c.model != nil && !c.model.MatchString(facts.Model) {
+		return false, nil
+	}
+	if c.TPMVersion != "" && c.TPMVersion != facts.TPMVersion {
+		return false, nil
+	}
+	if c.Key == "" {
+		return true, nil
+	}
+
+	value, err := get(c.Key)
+	if err != nil {
+		return false, err
+	}
+	if c.Equals != "" && value != c.Equals {
+		return false, nil
+	}
+	if c.NotEquals != "" && value == c.NotEquals {
+		return false, nil
+	}
+	return true, nil
+}
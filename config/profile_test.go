@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDefaultProfile(t *testing.T) {
+	t.Parallel()
+
+	p := DefaultProfile()
+	if len(p.Settings) == 0 {
+		t.Fatal("default profile has no settings")
+	}
+
+	loaded, err := LoadProfile("../testdata/no-such-profile.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Settings) != len(p.Settings) {
+		t.Error("default profile was not loaded for a missing file")
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{
+			name: "valid",
+			input: `
+version: 1
+settings:
+  - key: BIOS.ProcSettings.LogicalProc
+    value: Enabled
+    when:
+      model: "R6[0-9]0"
+`,
+			valid: true,
+		},
+		{
+			name: "unsupported version",
+			input: `
+version: 2
+settings: []
+`,
+		},
+		{
+			name: "unknown field",
+			input: `
+version: 1
+settings:
+  - key: BIOS.ProcSettings.LogicalProc
+    val: Enabled
+`,
+		},
+		{
+			name: "invalid TPM version",
+			input: `
+version: 1
+settings:
+  - key: BIOS.SysSecurity.TpmSecurity
+    value: "On"
+    when:
+      tpmVersion: "3.0"
+`,
+		},
+		{
+			name: "key without equals",
+			input: `
+version: 1
+settings:
+  - key: BIOS.SysSecurity.TpmCommand
+    value: Activate
+    when:
+      key: BIOS.SysSecurity.TpmStatus
+`,
+		},
+		{
+			name: "invalid model pattern",
+			input: `
+version: 1
+settings:
+  - key: BIOS.ProcSettings.LogicalProc
+    value: Enabled
+    when:
+      model: "("
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParseProfile([]byte(tc.input))
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: invalid profile was accepted", tc.name)
+		}
+	}
+}
+
+func TestConditionMatch(t *testing.T) {
+	t.Parallel()
+
+	p, err := ParseProfile([]byte(`
+version: 1
+settings:
+  - key: A
+    value: "1"
+    when:
+      model: "^PowerEdge R6"
+      tpmVersion: "1.2"
+      key: B
+      notEquals: Enabled
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond := p.Settings[0].When
+
+	values := map[string]string{"B": "Disabled"}
+	get := func(key string) (string, error) {
+		v, ok := values[key]
+		if !ok {
+			return "", errors.New("not found: " + key)
+		}
+		return v, nil
+	}
+
+	testCases := []struct {
+		facts    Facts
+		b        string
+		expected bool
+	}{
+		{Facts{Model: "PowerEdge R640", TPMVersion: "1.2"}, "Disabled", true},
+		{Facts{Model: "PowerEdge R740", TPMVersion: "1.2"}, "Disabled", false},
+		{Facts{Model: "PowerEdge R640", TPMVersion: "2.0"}, "Disabled", false},
+		{Facts{Model: "PowerEdge R640", TPMVersion: "1.2"}, "Enabled", false},
+	}
+	for _, tc := range testCases {
+		values["B"] = tc.b
+		ok, err := cond.Match(tc.facts, get)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tc.expected {
+			t.Errorf("unexpected result for %+v and B=%s: %v", tc.facts, tc.b, ok)
+		}
+	}
+
+	var nilCond *Condition
+	if ok, err := nilCond.Match(Facts{}, get); err != nil || !ok {
+		t.Error("nil condition was not satisfied")
+	}
+}
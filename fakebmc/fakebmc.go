@@ -0,0 +1,292 @@
+// Package fakebmc provides a fake BMC which serves Redfish data dumped by `collector show`.
+//
+// The fake BMC is an http.Handler, so it can be served with httptest.NewTLSServer
+// in tests, or with http.Server for local development.
+// It authenticates requests with basic authentication or Redfish sessions,
+// and injects faults such as delays, error statuses, and malformed JSON.
+package fakebmc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionsPath is the path of the Redfish session collection.
+const SessionsPath = "/redfish/v1/SessionService/Sessions"
+
+// AnyPath is the path given to SetFault to inject a fault to all pages.
+const AnyPath = "*"
+
+// Config is the configuration of a fake BMC.
+type Config struct {
+	// User and Password are the credentials accepted by the fake BMC.
+	User     string
+	Password string
+
+	// NoSession disables Redfish sessions, so that clients must use basic authentication.
+	NoSession bool
+
+	// NoAuth makes the fake BMC accept any credentials.
+	NoAuth bool
+}
+
+// Fault specifies a fault injected to responses.
+type Fault struct {
+	// Delay is the time to wait before responding.
+	Delay time.Duration
+
+	// Status is the HTTP status code returned instead of the page if not zero.
+	Status int
+
+	// Malformed makes the fake BMC return a broken JSON.
+	Malformed bool
+
+	// Count is the number of requests to which the fault is injected.
+	// If zero, the fault is injected to all requests.
+	Count int
+}
+
+// Server is a fake BMC.
+type Server struct {
+	pages  map[string]json.RawMessage
+	config Config
+
+	mu       sync.Mutex
+	sessions map[string]bool
+	faults   map[string]*Fault
+	requests map[string]int
+}
+
+// LoadDump reads Redfish data dumped by `collector show` from a file.
+func LoadDump(filename string) (map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDump(data)
+}
+
+// ParseDump parses Redfish data dumped by `collector show`.
+// The dump is a JSON object whose keys are paths and values are pages.
+func ParseDump(data []byte) (map[string]json.RawMessage, error) {
+	var pages map[string]json.RawMessage
+	if err := json.Unmarshal(data, &pages); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("no page in the dump")
+	}
+	return pages, nil
+}
+
+// New returns a fake BMC serving pages.
+func New(pages map[string]json.RawMessage, config Config) *Server {
+	normalized := make(map[string]json.RawMessage, len(pages))
+	for path, page := range pages {
+		normalized[normalizePath(path)] = page
+	}
+
+	return &Server{
+		pages:    normalized,
+		config:   config,
+		sessions: make(map[string]bool),
+		faults:   make(map[string]*Fault),
+		requests: make(map[string]int),
+	}
+}
+
+// normalizePath removes the trailing slash, because Redfish services serve
+// the same page with or without it.
+func normalizePath(path string) string {
+	if len(path) > 1 {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+// SetFault injects a fault to the responses of the page at path.
+// If path is AnyPath, the fault is injected to all pages without their own faults.
+// A zero Fault removes the fault.
+func (s *Server) SetFault(path string, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if path != AnyPath {
+		path = normalizePath(path)
+	}
+	if fault == (Fault{}) {
+		delete(s.faults, path)
+		return
+	}
+	s.faults[path] = &fault
+}
+
+// Requests returns the number of authenticated GET requests for the page at path.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[normalizePath(path)]
+}
+
+// Sessions returns the number of open sessions.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := normalizePath(r.URL.Path)
+
+	switch {
+	case r.Method == http.MethodPost && path == SessionsPath:
+		s.createSession(w, r)
+		return
+	case r.Method == http.MethodDelete && strings.HasPrefix(path, SessionsPath+"/"):
+		s.deleteSession(w, r, strings.TrimPrefix(path, SessionsPath+"/"))
+		return
+	case r.Method != http.MethodGet:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.authenticated(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Pages of a collection split by Members@odata.nextLink are dumped with their query strings.
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+
+	fault := s.countRequest(path)
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fault.Status != 0 {
+		http.Error(w, http.StatusText(fault.Status), fault.Status)
+		return
+	}
+
+	page, ok := s.pages[path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if fault.Malformed {
+		page = page[:len(page)/2]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(page)
+}
+
+// countRequest counts a request for path, and returns the fault to be injected to it.
+func (s *Server) countRequest(path string) Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[path]++
+
+	key := path
+	f, ok := s.faults[key]
+	if !ok {
+		key = AnyPath
+		f, ok = s.faults[key]
+	}
+	if !ok {
+		return Fault{}
+	}
+
+	fault := *f
+	if f.Count > 0 {
+		f.Count--
+		if f.Count == 0 {
+			delete(s.faults, key)
+		}
+	}
+	return fault
+}
+
+func (s *Server) authenticated(r *http.Request) bool {
+	if token := r.Header.Get("X-Auth-Token"); token != "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.sessions[token]
+	}
+
+	user, password, ok := r.BasicAuth()
+	return ok && s.validCredentials(user, password)
+}
+
+func (s *Server) validCredentials(user, password string) bool {
+	if s.config.NoAuth {
+		return true
+	}
+	return user == s.config.User && password == s.config.Password
+}
+
+func (s *Server) createSession(w http.ResponseWriter, r *http.Request) {
+	if s.config.NoSession {
+		http.NotFound(w, r)
+		return
+	}
+
+	var body struct {
+		UserName string
+		Password string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !s.validCredentials(body.UserName, body.Password) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.sessions[token] = true
+	s.mu.Unlock()
+
+	w.Header().Set("X-Auth-Token", token)
+	w.Header().Set("Location", SessionsPath+"/"+token)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) deleteSession(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token := r.Header.Get("X-Auth-Token")
+	if !s.sessions[token] {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !s.sessions[id] {
+		http.NotFound(w, r)
+		return
+	}
+	delete(s.sessions, id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,78 @@
+package fakebmc
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	pages, err := LoadDump("../testdata/fakebmc_dump.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(pages, Config{User: "support", Password: "secret", NoSession: true})
+
+	get := func(path string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.SetBasicAuth("support", "secret")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	// The root page is served with and without the trailing slash.
+	for _, path := range []string{"/redfish/v1", "/redfish/v1/"} {
+		if resp := get(path); resp.StatusCode != http.StatusOK {
+			t.Error("page was not served:", path, resp.StatusCode)
+		}
+	}
+	if resp := get("/redfish/v1/NoSuchPage"); resp.StatusCode != http.StatusNotFound {
+		t.Error("unknown page was served:", resp.StatusCode)
+	}
+	if n := s.Requests("/redfish/v1/"); n != 2 {
+		t.Error("wrong number of requests:", n)
+	}
+
+	s.SetFault(AnyPath, Fault{Delay: 50 * time.Millisecond, Status: http.StatusServiceUnavailable, Count: 1})
+	start := time.Now()
+	if resp := get("/redfish/v1/Chassis"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Error("fault was not injected:", resp.StatusCode)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("response was not delayed")
+	}
+	if resp := get("/redfish/v1/Chassis"); resp.StatusCode != http.StatusOK {
+		t.Error("fault was injected more than Count:", resp.StatusCode)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, SessionsPath, nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Result().StatusCode != http.StatusNotFound {
+		t.Error("session was created with NoSession:", w.Result().StatusCode)
+	}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	t.Parallel()
+
+	cert, err := SelfSignedCertificate([]string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := parsed.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+	if err := parsed.VerifyHostname("localhost"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,213 @@
+package idrac
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/setup-hw/config"
+)
+
+// Setting is a desired value of a key.
+type Setting struct {
+	Key string
+
+	// Value is the desired value as Get returns.
+	Value string
+
+	// SetValue is the value given to Set if it differs from Value, e.g. "1" for "Enabled".
+	SetValue string
+
+	// Secret is true if the value cannot be read back, e.g. a raw password.
+	// Such a setting is always written, and cannot be verified.
+	Secret bool
+
+	// When is the condition to apply the setting.  If nil, the setting is always applied.
+	When *config.Condition
+
+	facts config.Facts
+}
+
+// Match returns whether the condition of the setting is satisfied.
+// It must be called just before the setting is applied, because the condition may refer to
+// the current value of a key changed by a preceding setting.
+func (s *Setting) Match(ctx context.Context, r IDRAC) (bool, error) {
+	return s.When.Match(s.facts, func(key string) (string, error) {
+		return r.Get(ctx, key)
+	})
+}
+
+// WriteValue returns the value given to Set.
+func (s *Setting) WriteValue() string {
+	if s.SetValue != "" {
+		return s.SetValue
+	}
+	return s.Value
+}
+
+// Reboot returns whether the change of the setting takes effect after reboot.
+func (s *Setting) Reboot() bool {
+	return strings.HasPrefix(s.Key, "BIOS.")
+}
+
+// Desired is the desired state of BIOS and iDRAC.
+type Desired struct {
+	Profile       *config.Profile
+	AddressConfig *config.AddressConfig
+	UserConfig    *config.UserConfig
+
+	// Model is the product name of the server, e.g. "PowerEdge R640".
+	Model string
+
+	// Hostname is the name of the server.  iDRAC is named Hostname + "-idrac".
+	Hostname string
+}
+
+// Settings returns the desired settings in the order to be applied.
+// Conditions of the profile are not evaluated here; call Setting.Match for each setting in order.
+//
+// The address and the name of iDRAC follow the network settings of the profile,
+// e.g. iDRAC.IPv4.DHCPEnable, so that the static address is set as soon as DHCP is disabled.
+// Users are configured last.
+func (d *Desired) Settings(ctx context.Context, r IDRAC) ([]*Setting, error) {
+	profile, err := d.profileSettings(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := len(profile)
+	for i, s := range profile {
+		if strings.HasPrefix(s.Key, "iDRAC.NIC.") || strings.HasPrefix(s.Key, "iDRAC.IPv4.") {
+			pos = i + 1
+		}
+	}
+
+	cfg := d.AddressConfig.IPv4
+	settings := append([]*Setting{}, profile[:pos]...)
+	settings = append(settings,
+		&Setting{Key: "iDRAC.IPv4.Address", Value: cfg.Address},
+		&Setting{Key: "iDRAC.IPv4.Netmask", Value: cfg.Netmask},
+		&Setting{Key: "iDRAC.IPv4.Gateway", Value: cfg.Gateway},
+		&Setting{Key: "iDRAC.NIC.DNSRacName", Value: d.Hostname + "-idrac"},
+	)
+	settings = append(settings, profile[pos:]...)
+
+	settings = append(settings, userSettings("2", "root", "0x1ff", "4", d.UserConfig.Root)...)
+	settings = append(settings, userSettings("3", "support", "0x11", "15", d.UserConfig.Support)...)
+	settings = append(settings, userSettings("4", "power", "0x11", "3", d.UserConfig.Power)...)
+	return settings, nil
+}
+
+// profileSettings returns the settings of the profile with facts of the server read from r.
+func (d *Desired) profileSettings(ctx context.Context, r IDRAC) ([]*Setting, error) {
+	tpm, err := tpmVersion(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	facts := config.Facts{
+		Model:      d.Model,
+		TPMVersion: tpm,
+	}
+
+	settings := make([]*Setting, len(d.Profile.Settings))
+	for i, s := range d.Profile.Settings {
+		settings[i] = &Setting{
+			Key:      s.Key,
+			Value:    s.Value,
+			SetValue: s.SetValue,
+			When:     s.When,
+			facts:    facts,
+		}
+	}
+	return settings, nil
+}
+
+// tpmVersion returns the version of TPM, "2.0" or "1.2", or an empty string if TPM is not found.
+func tpmVersion(ctx context.Context, r IDRAC) (string, error) {
+	val, err := r.Get(ctx, "BIOS.SysSecurity.TpmInfo")
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case strings.Contains(val, "2.0"):
+		return "2.0", nil
+	case strings.Contains(val, "1.2"):
+		return "1.2", nil
+	}
+
+	log.Warn("tpm not found", map[string]interface{}{
+		"tpminfo": val,
+	})
+	return "", nil
+}
+
+func userSettings(idx, name, priv, ipmiPriv string, cred config.Credentials) []*Setting {
+	// ipmipriv:
+	// - 1 Callback level
+	// - 2 User level
+	// - 3 Operator level
+	// - 4 Administrator level
+	// - 5 OEM Proprietary level
+	// - 15 No access
+
+	prefix := "iDRAC.Users." + idx + "."
+	settings := []*Setting{
+		{Key: prefix + "Username", Value: name},
+	}
+	if cred.Password.Raw != "" {
+		settings = append(settings, &Setting{Key: prefix + "Password", Value: cred.Password.Raw, Secret: true})
+	} else {
+		settings = append(settings,
+			&Setting{Key: prefix + "SHA256Password", Value: cred.Password.Hash},
+			&Setting{Key: prefix + "SHA256PasswordSalt", Value: cred.Password.Salt},
+		)
+	}
+	return append(settings,
+		&Setting{Key: prefix + "Privilege", Value: priv},
+		&Setting{Key: prefix + "IpmiLanPrivilege", Value: ipmiPriv},
+		&Setting{Key: prefix + "IpmiSerialPrivilege", Value: ipmiPriv},
+		&Setting{Key: prefix + "Enable", Value: "Enabled"},
+	)
+}
+
+// Result is the result of comparing the current value of a key with the desired one.
+type Result struct {
+	Key       string `json:"key"`
+	Current   string `json:"current"`
+	Desired   string `json:"desired"`
+	Compliant bool   `json:"compliant"`
+}
+
+// Verify compares the current values read from r with the desired settings without changing anything.
+// Secret settings and settings whose conditions are not satisfied are skipped.
+func (d *Desired) Verify(ctx context.Context, r IDRAC) ([]*Result, error) {
+	settings, err := d.Settings(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Result
+	for _, s := range settings {
+		if s.Secret {
+			continue
+		}
+		ok, err := s.Match(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		cur, err := r.Get(ctx, s.Key)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &Result{
+			Key:       s.Key,
+			Current:   cur,
+			Desired:   s.Value,
+			Compliant: cur == s.Value,
+		})
+	}
+	return results, nil
+}
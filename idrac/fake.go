@@ -0,0 +1,232 @@
+package idrac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Fake is an in-memory IDRAC for tests.
+// It holds settings as a key/value store, and jobs as a queue.
+type Fake struct {
+	mu          sync.Mutex
+	values      map[string]string
+	aliases     map[string]map[string]string
+	sets        []string
+	jobs        []string
+	scheduled   bool
+	updates     []string
+	remoteImage string
+	errors      map[string]error
+}
+
+var _ IDRAC = (*Fake)(nil)
+
+// NewFake returns a Fake having the given settings.
+func NewFake(values map[string]string) *Fake {
+	f := &Fake{
+		values:  make(map[string]string),
+		aliases: make(map[string]map[string]string),
+		errors:  make(map[string]error),
+	}
+	for k, v := range values {
+		f.values[k] = v
+	}
+	return f
+}
+
+// SetAlias makes the value written as setValue to key read as value,
+// e.g. "iDRAC.IPMILan.Enable" written as "1" is read as "Enabled".
+func (f *Fake) SetAlias(key, setValue, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.aliases[key] == nil {
+		f.aliases[key] = make(map[string]string)
+	}
+	f.aliases[key][setValue] = value
+}
+
+// SetError makes the operation fail with err.
+// op is the name of a method, or "Get:KEY" and "Set:KEY" for a specific key.
+// A nil err removes the error.
+func (f *Fake) SetError(op string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.errors, op)
+		return
+	}
+	f.errors[op] = err
+}
+
+// ScheduleJob puts a scheduled job in the queue, as if it was created by someone else.
+func (f *Fake) ScheduleJob(target string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs = append(f.jobs, target)
+	f.scheduled = true
+}
+
+// CompleteJobs marks all jobs in the queue completed, as if the server was rebooted.
+func (f *Fake) CompleteJobs() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scheduled = false
+}
+
+// Value returns the current value of key.
+func (f *Fake) Value(key string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.values[key]
+}
+
+// Sets returns the history of settings written, as "KEY=VALUE".
+func (f *Fake) Sets() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.sets...)
+}
+
+// Jobs returns the targets of the jobs created.
+func (f *Fake) Jobs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.jobs...)
+}
+
+// Updates returns the files given to Update.
+func (f *Fake) Updates() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.updates...)
+}
+
+// RemoteImage returns the URL of the connected remote image.
+func (f *Fake) RemoteImage() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.remoteImage
+}
+
+// err returns the error injected to op.  The caller must hold f.mu.
+func (f *Fake) err(ops ...string) error {
+	for _, op := range ops {
+		if err, ok := f.errors[op]; ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get implements IDRAC.
+func (f *Fake) Get(ctx context.Context, key string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.err("Get", "Get:"+key); err != nil {
+		return "", err
+	}
+	v, ok := f.values[key]
+	if !ok {
+		return "", fmt.Errorf("unknown key: %s", key)
+	}
+	return v, nil
+}
+
+// Set implements IDRAC.
+func (f *Fake) Set(ctx context.Context, key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.err("Set", "Set:"+key); err != nil {
+		return err
+	}
+	f.sets = append(f.sets, key+"="+value)
+	if alias, ok := f.aliases[key][value]; ok {
+		value = alias
+	}
+	f.values[key] = value
+	return nil
+}
+
+// SetSecret implements IDRAC.
+func (f *Fake) SetSecret(ctx context.Context, key, value string) error {
+	return f.Set(ctx, key, value)
+}
+
+// JobQueueView implements IDRAC.
+// The output lists the jobs like racadm does.
+func (f *Fake) JobQueueView(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.err("JobQueueView"); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("-------------------------JOB QUEUE------------------------\n")
+	status := "Completed"
+	if f.scheduled {
+		status = "Scheduled"
+	}
+	for i, job := range f.jobs {
+		fmt.Fprintf(&sb, "[Job ID=JID_%012d]\nJob Name=Configure: %s\nStatus=%s\n", i, job, status)
+	}
+	return sb.String(), nil
+}
+
+// JobQueueCreate implements IDRAC.
+func (f *Fake) JobQueueCreate(ctx context.Context, target string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.err("JobQueueCreate"); err != nil {
+		return err
+	}
+	f.jobs = append(f.jobs, target)
+	f.scheduled = true
+	return nil
+}
+
+// Update implements IDRAC.
+// It returns the output of a successful update initiation.
+func (f *Fake) Update(ctx context.Context, file string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.err("Update"); err != nil {
+		return "", err
+	}
+	f.updates = append(f.updates, file)
+	return "Applying...\nRAC987: Update initiated.\n", nil
+}
+
+// VMDisconnect implements IDRAC.
+func (f *Fake) VMDisconnect(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err("VMDisconnect")
+}
+
+// RemoteImageConnect implements IDRAC.
+func (f *Fake) RemoteImageConnect(ctx context.Context, url string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.err("RemoteImageConnect"); err != nil {
+		return err
+	}
+	if f.remoteImage != "" {
+		return fmt.Errorf("remote image is already connected: %s", f.remoteImage)
+	}
+	f.remoteImage = url
+	return nil
+}
+
+// RemoteImageDisconnect implements IDRAC.
+func (f *Fake) RemoteImageDisconnect(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.err("RemoteImageDisconnect"); err != nil {
+		return err
+	}
+	f.remoteImage = ""
+	return nil
+}
@@ -0,0 +1,40 @@
+// Package idrac provides the interface to configure iDRAC, the BMC of Dell servers.
+//
+// Racadm implements the interface with idracadm7 from OMSA, and Fake
+// implements it in memory for tests.
+package idrac
+
+import (
+	"context"
+)
+
+// IDRAC is the interface to configure iDRAC.
+type IDRAC interface {
+	// Get returns the current value of key, e.g. "iDRAC.SNMP.AgentEnable".
+	Get(ctx context.Context, key string) (string, error)
+
+	// Set sets key to value.
+	Set(ctx context.Context, key, value string) error
+
+	// SetSecret sets key to value without leaving value in logs.
+	SetSecret(ctx context.Context, key, value string) error
+
+	// JobQueueView returns the list of jobs in the output format of "racadm jobqueue view".
+	JobQueueView(ctx context.Context) (string, error)
+
+	// JobQueueCreate creates a job to apply pending settings of the target, e.g. "BIOS.Setup.1-1".
+	JobQueueCreate(ctx context.Context, target string) error
+
+	// Update initiates a firmware update with the file, and returns the output of "racadm update".
+	// The caller should check the output because racadm does not tell the result by the exit status.
+	Update(ctx context.Context, file string) (string, error)
+
+	// VMDisconnect disconnects virtual media.  It succeeds if virtual media is not connected.
+	VMDisconnect(ctx context.Context) error
+
+	// RemoteImageConnect connects the remote image at url as virtual media.
+	RemoteImageConnect(ctx context.Context, url string) error
+
+	// RemoteImageDisconnect disconnects the remote image.  It succeeds if no image is connected.
+	RemoteImageDisconnect(ctx context.Context) error
+}
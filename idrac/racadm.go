@@ -0,0 +1,213 @@
+package idrac
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/well"
+	"gopkg.in/ini.v1"
+)
+
+// RacadmPath is the path of idracadm7 installed by OMSA.
+const RacadmPath = "/opt/dell/srvadmin/bin/idracadm7"
+
+const (
+	retryCount    = 5
+	retryInterval = 10 * time.Second
+
+	// settleTime is the time to wait after a successful set, because iDRAC
+	// may fail to handle the next command immediately.
+	settleTime = 1 * time.Second
+)
+
+// Racadm implements IDRAC with idracadm7.
+type Racadm struct {
+	path   string
+	tries  int
+	settle time.Duration
+}
+
+// NewRacadm returns a Racadm which runs idracadm7 at RacadmPath.
+// Set and SetSecret are retried up to 5 times at intervals of 10 seconds.
+func NewRacadm() *Racadm {
+	return &Racadm{path: RacadmPath, tries: retryCount, settle: settleTime}
+}
+
+// NewSingleShotRacadm returns a Racadm which runs idracadm7 at RacadmPath.
+// Unlike NewRacadm, Set and SetSecret run idracadm7 only once and return immediately.
+func NewSingleShotRacadm() *Racadm {
+	return &Racadm{path: RacadmPath, tries: 1}
+}
+
+var _ IDRAC = (*Racadm)(nil)
+
+func (r *Racadm) run(ctx context.Context, args ...string) (string, error) {
+	cmd := well.CommandContext(ctx, r.path, args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// retry runs idracadm7 until it succeeds up to r.tries times.
+// If silent is true, the arguments are not logged because they contain secrets.
+func (r *Racadm) retry(ctx context.Context, silent bool, args ...string) error {
+	retries := 0
+RETRY:
+	var err error
+	var out []byte
+	if silent {
+		cmd := exec.CommandContext(ctx, r.path, args...)
+		out, err = cmd.CombinedOutput()
+	} else {
+		cmd := well.CommandContext(ctx, r.path, args...)
+		err = cmd.Run()
+	}
+	if err == nil {
+		time.Sleep(r.settle)
+		return nil
+	}
+
+	retries++
+	if retries >= r.tries {
+		if silent {
+			log.Error("idracadm7 failed", map[string]interface{}{
+				log.FnError: err,
+				"output":    string(out),
+			})
+		}
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(retryInterval):
+	}
+	goto RETRY
+}
+
+// Get returns a string corresponding to the given key.
+// In many cases 'idracadm7 get KEY' returns INI format output,
+// but in some cases it returns the value not conforming to INI format.
+//
+// case1: conform to INI format
+//
+//	$ sudo idracadm7 get iDRAC.SNMP.AgentEnable
+//	[Key=iDRAC.Embedded.1#SNMP.1]
+//	AgentEnable=Enabled
+//
+// case2: not conform to INI format. return only value.
+//
+//	$ sudo idracadm7 get System.ServerPwr.PSRapidOn
+//	Enabled
+func (r *Racadm) Get(ctx context.Context, key string) (string, error) {
+	cmd := well.CommandContext(ctx, r.path, "get", key)
+	cmd.Severity = log.LvDebug
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	return parseGetOutput(string(out), key)
+}
+
+func parseGetOutput(out, key string) (string, error) {
+	if !strings.HasPrefix(out, "[") {
+		return strings.TrimSpace(out), nil
+	}
+
+	cfg, err := ini.Load([]byte(out))
+	if err != nil {
+		return "", err
+	}
+
+	var sectionName string
+	for _, name := range cfg.SectionStrings() {
+		if name != ini.DefaultSection {
+			sectionName = name
+			break
+		}
+	}
+
+	section := cfg.Section(sectionName)
+	keys := section.Keys()
+	if len(keys) == 0 {
+		return "", errors.New("unexpected output for " + key)
+	}
+
+	return keys[0].String(), nil
+}
+
+// Set sets key to value with retries.
+func (r *Racadm) Set(ctx context.Context, key, value string) error {
+	return r.retry(ctx, false, "set", key, value)
+}
+
+// SetSecret sets key to value with retries.  The value is not logged.
+func (r *Racadm) SetSecret(ctx context.Context, key, value string) error {
+	return r.retry(ctx, true, "set", key, value)
+}
+
+// JobQueueView returns the output of "idracadm7 jobqueue view".
+func (r *Racadm) JobQueueView(ctx context.Context) (string, error) {
+	return r.run(ctx, "jobqueue", "view")
+}
+
+// JobQueueCreate runs "idracadm7 jobqueue create".
+func (r *Racadm) JobQueueCreate(ctx context.Context, target string) error {
+	_, err := r.run(ctx, "jobqueue", "create", target)
+	return err
+}
+
+// Update runs "idracadm7 update -f".
+func (r *Racadm) Update(ctx context.Context, file string) (string, error) {
+	cmd := well.CommandContext(ctx, r.path, "update", "-f", file)
+	buf := bytes.Buffer{}
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	err := cmd.Run()
+	// we cannot use exit status to detect errors because `idracadm7 update` returns nonzero status even in case of successful update initiation.
+	var exitError *exec.ExitError
+	if err != nil && !errors.As(err, &exitError) {
+		return "", fmt.Errorf("racadm update failed at file %s: %w", file, err)
+	}
+	return buf.String(), nil
+}
+
+// VMDisconnect runs "idracadm7 vmdisconnect".
+func (r *Racadm) VMDisconnect(ctx context.Context) error {
+	err := well.CommandContext(ctx, r.path, "vmdisconnect").Run()
+	// we cannot use exit status to detect errors because `idracadm7 vmdisconnect` returns nonzero status if it is not connected.
+	var exitError *exec.ExitError
+	if err != nil && !errors.As(err, &exitError) {
+		return fmt.Errorf("racadm vmdisconnect failed: %w", err)
+	}
+	return nil
+}
+
+// RemoteImageConnect runs "idracadm7 remoteimage -c".
+func (r *Racadm) RemoteImageConnect(ctx context.Context, url string) error {
+	err := well.CommandContext(ctx, r.path, "remoteimage", "-c", "-l", url).Run()
+	if err != nil {
+		return fmt.Errorf("racadm remoteimage -c failed: %w", err)
+	}
+	return nil
+}
+
+// RemoteImageDisconnect runs "idracadm7 remoteimage -d".
+func (r *Racadm) RemoteImageDisconnect(ctx context.Context) error {
+	// `idracadm7 remoteimage -d` returns zero if the remote image is not connected.
+	err := well.CommandContext(ctx, r.path, "remoteimage", "-d").Run()
+	if err != nil {
+		return fmt.Errorf("racadm remoteimage -d failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package idrac
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGetOutput(t *testing.T) {
+	t.Parallel()
+
+	val, err := parseGetOutput(`[Key=iDRAC.Embedded.1#SNMP.1]
+	AgentEnable=Enabled
+	
+	`, "iDRAC.SNMP.AgentEnable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != "Enabled" {
+		t.Error("unexpected value:", val)
+	}
+
+	val, err = parseGetOutput(`Enabled
+	
+	`, "System.ServerPwr.PSRapidOn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != "Enabled" {
+		t.Error("unexpected value:", val)
+	}
+}
+
+func TestRacadmSingleShot(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	count := filepath.Join(dir, "count")
+	script := filepath.Join(dir, "idracadm7")
+	err := os.WriteFile(script, []byte("#!/bin/sh\necho >> "+count+"\nexit 1\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewSingleShotRacadm()
+	r.path = script
+	if err := r.Set(context.Background(), "iDRAC.VirtualMedia.BootOnce", "1"); err == nil {
+		t.Error("Set should fail")
+	}
+
+	data, err := os.ReadFile(count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 1 {
+		t.Error("idracadm7 should run only once, but ran", n, "times")
+	}
+}
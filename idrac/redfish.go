@@ -0,0 +1,522 @@
+package idrac
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/log"
+)
+
+const (
+	redfishSystemID  = "System.Embedded.1"
+	redfishManagerID = "iDRAC.Embedded.1"
+
+	// biosJobTarget is the target of JobQueueCreate to apply pending BIOS settings.
+	biosJobTarget = "BIOS.Setup.1-1"
+
+	redfishRequestTimeout = 30 * time.Second
+
+	// redfishMaxJobPages is the maximum number of pages of the job collection to be read.
+	redfishMaxJobPages = 1000
+)
+
+// ErrNotSupported is returned by operations a backend does not support.
+var ErrNotSupported = errors.New("not supported")
+
+// Redfish implements IDRAC with the Redfish API of iDRAC.
+//
+// Keys are those of racadm, and values are converted to and from those of racadm,
+// so that callers need not know which implementation they use:
+//
+//   - "BIOS.Group.Name" is the attribute Name of Systems/<id>/Bios,
+//     and it is changed through Systems/<id>/Bios/Settings.
+//   - "iDRAC.Group.Name" and "System.Group.Name" are the attribute "Group.1.Name"
+//     of Managers/iDRAC.Embedded.1 and Managers/System.Embedded.1, respectively.
+//   - "iDRAC.Users.N.Username", "iDRAC.Users.N.Password" and "iDRAC.Users.N.Enable"
+//     are the properties of AccountService/Accounts/N.  Other properties of users
+//     are the manager attributes "Users.N.Name".
+//
+// Resources are read once and cached until they are changed through Redfish.
+// Requests failed by network errors or server errors are retried as racadm set is.
+//
+// Update and virtual media are not supported.
+type Redfish struct {
+	endpoint *url.URL
+	client   *http.Client
+	tries    int
+	interval time.Duration
+
+	mu        sync.Mutex
+	user      string
+	password  string
+	resources map[string]map[string]interface{}
+}
+
+var _ IDRAC = (*Redfish)(nil)
+
+// NewRedfish returns a Redfish accessing iDRAC at endpoint, e.g. "https://169.254.0.1",
+// as user with password.
+func NewRedfish(endpoint, user, password string) (*Redfish, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Redfish endpoint: %s", endpoint)
+	}
+
+	return &Redfish{
+		endpoint: u,
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+			},
+			Timeout: redfishRequestTimeout,
+		},
+		tries:     retryCount,
+		interval:  retryInterval,
+		user:      user,
+		password:  password,
+		resources: make(map[string]map[string]interface{}),
+	}, nil
+}
+
+// redfishProperty is a property of a Redfish resource corresponding to a racadm key.
+type redfishProperty struct {
+	// path is the resource to read the property.
+	path string
+
+	// settingsPath is the resource to write the property.
+	settingsPath string
+
+	// attribute is true if the property is in "Attributes" of the resource.
+	attribute bool
+	name      string
+
+	// enumKey is the key of racadmEnums, which is the racadm key without the index of users.
+	enumKey string
+}
+
+// resolve returns the Redfish property corresponding to key.
+func resolve(key string) (*redfishProperty, error) {
+	parts := strings.Split(key, ".")
+	switch {
+	case len(parts) == 3 && parts[0] == "BIOS":
+		path := "/redfish/v1/Systems/" + redfishSystemID + "/Bios"
+		return &redfishProperty{
+			path:         path,
+			settingsPath: path + "/Settings",
+			attribute:    true,
+			name:         parts[2],
+			enumKey:      key,
+		}, nil
+
+	case len(parts) == 4 && parts[0] == "iDRAC" && parts[1] == "Users":
+		enumKey := "iDRAC.Users." + parts[3]
+		accountProperties := map[string]string{
+			"Username": "UserName",
+			"Password": "Password",
+			"Enable":   "Enabled",
+		}
+		if name, ok := accountProperties[parts[3]]; ok {
+			path := "/redfish/v1/AccountService/Accounts/" + parts[2]
+			return &redfishProperty{
+				path:         path,
+				settingsPath: path,
+				name:         name,
+				enumKey:      enumKey,
+			}, nil
+		}
+		path := "/redfish/v1/Managers/" + redfishManagerID + "/Attributes"
+		return &redfishProperty{
+			path:         path,
+			settingsPath: path,
+			attribute:    true,
+			name:         "Users." + parts[2] + "." + parts[3],
+			enumKey:      enumKey,
+		}, nil
+
+	case len(parts) == 3 && (parts[0] == "iDRAC" || parts[0] == "System"):
+		manager := redfishManagerID
+		if parts[0] == "System" {
+			manager = redfishSystemID
+		}
+		path := "/redfish/v1/Managers/" + manager + "/Attributes"
+		return &redfishProperty{
+			path:         path,
+			settingsPath: path,
+			attribute:    true,
+			name:         parts[1] + ".1." + parts[2],
+			enumKey:      key,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key for Redfish: %s", key)
+}
+
+// racadmEnum maps values of racadm to those of Redfish.
+type racadmEnum struct {
+	values map[string]string
+
+	// numeric is true if racadm shows the value as it is set, e.g. "3" instead of "Operator".
+	// Otherwise, only the value to set is converted.
+	numeric bool
+}
+
+var ipmiPrivileges = map[string]string{
+	"1":  "Callback",
+	"2":  "User",
+	"3":  "Operator",
+	"4":  "Administrator",
+	"5":  "OEM",
+	"15": "No Access",
+}
+
+var racadmEnums = map[string]racadmEnum{
+	"iDRAC.IPMILan.PrivLimit":         {values: ipmiPrivileges, numeric: true},
+	"iDRAC.Users.IpmiLanPrivilege":    {values: ipmiPrivileges, numeric: true},
+	"iDRAC.Users.IpmiSerialPrivilege": {values: ipmiPrivileges, numeric: true},
+	"iDRAC.VirtualConsole.PluginType": {values: map[string]string{"0": "ActiveX", "1": "Java", "2": "HTML5"}, numeric: true},
+	"iDRAC.IPMILan.Enable":            {values: map[string]string{"0": "Disabled", "1": "Enabled"}},
+	"iDRAC.Users.Enable":              {values: map[string]string{"0": "Disabled", "1": "Enabled"}},
+	"System.ThermalSettings.FanSpeedOffset": {values: map[string]string{
+		"0": "Low", "1": "High", "2": "Medium", "3": "Max", "255": "Off",
+	}},
+}
+
+// toRacadm converts a value of Redfish to the one racadm shows.
+func (p *redfishProperty) toRacadm(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "Enabled"
+		}
+		return "Disabled"
+	case float64:
+		// racadm shows the privilege of users as a bitmask.
+		if p.enumKey == "iDRAC.Users.Privilege" {
+			return fmt.Sprintf("0x%x", int64(v))
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		enum, ok := racadmEnums[p.enumKey]
+		if !ok || !enum.numeric {
+			return v
+		}
+		for racadmValue, redfishValue := range enum.values {
+			if redfishValue == v {
+				return racadmValue
+			}
+		}
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// fromRacadm converts a value given to racadm to the one of Redfish.
+// current is the current value of the property, whose type is kept.
+func (p *redfishProperty) fromRacadm(value string, current interface{}) (interface{}, error) {
+	if enum, ok := racadmEnums[p.enumKey]; ok {
+		if v, ok := enum.values[value]; ok {
+			value = v
+		}
+	}
+
+	switch current.(type) {
+	case bool:
+		switch value {
+		case "Enabled", "true":
+			return true, nil
+		case "Disabled", "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid value for %s: %s", p.name, value)
+	case float64:
+		n, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", p.name, value)
+		}
+		return n, nil
+	}
+	return value, nil
+}
+
+// do sends a request with body encoded in JSON, and decodes the response into out if not nil.
+// The request is retried up to r.tries times if it fails by a network error or a server error.
+// POST is not retried because it is not idempotent.
+func (r *Redfish) do(ctx context.Context, method, path string, body, out interface{}) error {
+	retries := 0
+	for {
+		retryable, err := r.doOnce(ctx, method, path, body, out)
+		if err == nil {
+			return nil
+		}
+
+		retries++
+		if !retryable || method == http.MethodPost || retries >= r.tries {
+			return err
+		}
+		log.Warn("Redfish request failed; retrying", map[string]interface{}{
+			"method":    method,
+			"path":      path,
+			log.FnError: err,
+		})
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.interval):
+		}
+	}
+}
+
+// doOnce sends a request once.  It also returns whether the request may succeed if retried.
+func (r *Redfish) doOnce(ctx context.Context, method, path string, body, out interface{}) (bool, error) {
+	u, err := r.endpoint.Parse(path)
+	if err != nil {
+		return false, err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return false, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	req.SetBasicAuth(r.user, r.password)
+	r.mu.Unlock()
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("%s %s: %d: %s", method, u.Path, resp.StatusCode, strings.TrimSpace(string(data)))
+		return resp.StatusCode >= 500, err
+	}
+
+	if out == nil || len(data) == 0 {
+		return false, nil
+	}
+	return false, json.Unmarshal(data, out)
+}
+
+// getResource returns the resource at path.  It is fetched only if it is not cached.
+func (r *Redfish) getResource(ctx context.Context, path string) (map[string]interface{}, error) {
+	r.mu.Lock()
+	resource, ok := r.resources[path]
+	r.mu.Unlock()
+	if ok {
+		return resource, nil
+	}
+
+	if err := r.do(ctx, http.MethodGet, path, nil, &resource); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resources[path] = resource
+	return resource, nil
+}
+
+// getProperty returns the current value of the property.
+func (r *Redfish) getProperty(ctx context.Context, p *redfishProperty) (interface{}, error) {
+	resource, err := r.getResource(ctx, p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.attribute {
+		attrs, ok := resource["Attributes"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("no attributes in %s", p.path)
+		}
+		resource = attrs
+	}
+	v, ok := resource[p.name]
+	if !ok {
+		return nil, fmt.Errorf("%s is not found in %s", p.name, p.path)
+	}
+	return v, nil
+}
+
+// Get implements IDRAC.
+func (r *Redfish) Get(ctx context.Context, key string) (string, error) {
+	p, err := resolve(key)
+	if err != nil {
+		return "", err
+	}
+	v, err := r.getProperty(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	return p.toRacadm(v), nil
+}
+
+// Set implements IDRAC.
+// Changes of BIOS settings are pending until a job is created by JobQueueCreate.
+func (r *Redfish) Set(ctx context.Context, key, value string) error {
+	p, err := resolve(key)
+	if err != nil {
+		return err
+	}
+
+	var current interface{}
+	if p.name != "Password" {
+		current, err = r.getProperty(ctx, p)
+		if err != nil {
+			return err
+		}
+	}
+	v, err := p.fromRacadm(value, current)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{p.name: v}
+	if p.attribute {
+		body = map[string]interface{}{"Attributes": body}
+	}
+	err = r.do(ctx, http.MethodPatch, p.settingsPath, body, nil)
+
+	// The resource is read again because the change may be applied or rejected in part.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.resources, p.settingsPath)
+	return err
+}
+
+// SetSecret implements IDRAC.
+// If the password of the user accessing Redfish is changed, the new password is used afterward.
+func (r *Redfish) SetSecret(ctx context.Context, key, value string) error {
+	var name string
+	if strings.HasPrefix(key, "iDRAC.Users.") && strings.HasSuffix(key, ".Password") {
+		// This must be read before the change because the current password may be changed.
+		n, err := r.Get(ctx, strings.TrimSuffix(key, "Password")+"Username")
+		if err != nil {
+			return err
+		}
+		name = n
+	}
+
+	if err := r.Set(ctx, key, value); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name != "" && name == r.user {
+		r.password = value
+	}
+	return nil
+}
+
+// JobQueueView implements IDRAC.
+// The output lists the jobs in the same format as racadm.
+// The job collection is followed through Members@odata.nextLink because iDRAC keeps completed jobs in it.
+func (r *Redfish) JobQueueView(ctx context.Context) (string, error) {
+	var ids []string
+	path := "/redfish/v1/Managers/" + redfishManagerID + "/Jobs"
+	seen := make(map[string]bool)
+	for path != "" {
+		if seen[path] || len(seen) >= redfishMaxJobPages {
+			return "", fmt.Errorf("too many pages or a loop in the job collection: %s", path)
+		}
+		seen[path] = true
+
+		var collection struct {
+			Members []struct {
+				ID string `json:"@odata.id"`
+			}
+			NextLink string `json:"Members@odata.nextLink"`
+		}
+		if err := r.do(ctx, http.MethodGet, path, nil, &collection); err != nil {
+			return "", err
+		}
+		for _, member := range collection.Members {
+			ids = append(ids, member.ID)
+		}
+		path = collection.NextLink
+	}
+
+	var sb strings.Builder
+	sb.WriteString("-------------------------JOB QUEUE------------------------\n")
+	for _, id := range ids {
+		var job struct {
+			ID       string `json:"Id"`
+			Name     string
+			JobState string
+		}
+		if err := r.do(ctx, http.MethodGet, id, nil, &job); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "[Job ID=%s]\nJob Name=%s\nStatus=%s\n", job.ID, job.Name, job.JobState)
+	}
+	return sb.String(), nil
+}
+
+// JobQueueCreate implements IDRAC.
+// Only "BIOS.Setup.1-1" is supported as target.
+func (r *Redfish) JobQueueCreate(ctx context.Context, target string) error {
+	if target != biosJobTarget {
+		return fmt.Errorf("unsupported job target for Redfish: %s", target)
+	}
+	body := map[string]string{
+		"TargetSettingsURI": "/redfish/v1/Systems/" + redfishSystemID + "/Bios/Settings",
+	}
+	return r.do(ctx, http.MethodPost, "/redfish/v1/Managers/"+redfishManagerID+"/Jobs", body, nil)
+}
+
+// Update implements IDRAC.  It is not supported.
+func (r *Redfish) Update(ctx context.Context, file string) (string, error) {
+	return "", fmt.Errorf("update with Redfish: %w", ErrNotSupported)
+}
+
+// VMDisconnect implements IDRAC.  It is not supported.
+func (r *Redfish) VMDisconnect(ctx context.Context) error {
+	return fmt.Errorf("vmdisconnect with Redfish: %w", ErrNotSupported)
+}
+
+// RemoteImageConnect implements IDRAC.  It is not supported.
+func (r *Redfish) RemoteImageConnect(ctx context.Context, url string) error {
+	return fmt.Errorf("remote image with Redfish: %w", ErrNotSupported)
+}
+
+// RemoteImageDisconnect implements IDRAC.  It is not supported.
+func (r *Redfish) RemoteImageDisconnect(ctx context.Context) error {
+	return fmt.Errorf("remote image with Redfish: %w", ErrNotSupported)
+}
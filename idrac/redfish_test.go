@@ -0,0 +1,423 @@
+package idrac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// redfishServer emulates the part of iDRAC Redfish API used by Redfish.
+type redfishServer struct {
+	mu        sync.Mutex
+	password  string
+	resources map[string]map[string]interface{}
+
+	// requests counts all requests, and gets counts GET requests of each path.
+	requests int
+	gets     map[string]int
+
+	// failures is the number of the next requests to fail with 503.
+	failures int
+}
+
+func newRedfishServer() *redfishServer {
+	return &redfishServer{
+		password: "calvin",
+		gets:     make(map[string]int),
+		resources: map[string]map[string]interface{}{
+			"/redfish/v1/Systems/System.Embedded.1/Bios": {
+				"Attributes": map[string]interface{}{
+					"LogicalProc": "Enabled",
+					"TpmInfo":     "Type: 2.0 NTC",
+				},
+			},
+			"/redfish/v1/Systems/System.Embedded.1/Bios/Settings": {
+				"Attributes": map[string]interface{}{},
+			},
+			"/redfish/v1/Managers/iDRAC.Embedded.1/Attributes": {
+				"Attributes": map[string]interface{}{
+					"Info.1.Name":                 "iDRAC",
+					"IPMILan.1.PrivLimit":         "Administrator",
+					"IPMILan.1.Enable":            "Disabled",
+					"Users.2.Privilege":           float64(1),
+					"Users.2.IpmiLanPrivilege":    "No Access",
+					"VirtualConsole.1.PluginType": "ActiveX",
+				},
+			},
+			"/redfish/v1/Managers/System.Embedded.1/Attributes": {
+				"Attributes": map[string]interface{}{
+					"ThermalSettings.1.FanSpeedOffset": "Off",
+				},
+			},
+			"/redfish/v1/AccountService/Accounts/2": {
+				"UserName": "root",
+				"Enabled":  false,
+			},
+			"/redfish/v1/Managers/iDRAC.Embedded.1/Jobs": {
+				"Members": []interface{}{},
+			},
+		},
+	}
+}
+
+func (s *redfishServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok || user != "root" || password != s.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := r.URL.Path
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	res, ok := s.resources[path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.gets[r.URL.Path]++
+		json.NewEncoder(w).Encode(res)
+
+	case http.MethodPatch:
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for k, v := range body {
+			if k == "Attributes" {
+				attrs := res["Attributes"].(map[string]interface{})
+				for name, value := range v.(map[string]interface{}) {
+					attrs[name] = value
+				}
+				continue
+			}
+			if k == "Password" {
+				if res["UserName"] == "root" {
+					s.password = v.(string)
+				}
+				continue
+			}
+			res[k] = v
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodPost:
+		if !strings.HasSuffix(r.URL.Path, "/Jobs") {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["TargetSettingsURI"] == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		id := "/redfish/v1/Managers/iDRAC.Embedded.1/Jobs/JID_1"
+		s.resources[id] = map[string]interface{}{
+			"Id":       "JID_1",
+			"Name":     "Configure: BIOS.Setup.1-1",
+			"JobState": "Scheduled",
+		}
+		res["Members"] = append(res["Members"].([]interface{}), map[string]interface{}{"@odata.id": id})
+		w.Header().Set("Location", id)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (s *redfishServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *redfishServer) getCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gets[path]
+}
+
+func (s *redfishServer) fail(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+func (s *redfishServer) attribute(path, name string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resources[path]["Attributes"].(map[string]interface{})[name]
+}
+
+func TestRedfish(t *testing.T) {
+	t.Parallel()
+
+	server := newRedfishServer()
+	ts := httptest.NewTLSServer(server)
+	defer ts.Close()
+
+	r, err := NewRedfish(ts.URL, "root", "calvin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	gets := map[string]string{
+		"iDRAC.Info.Name":                       "iDRAC",
+		"BIOS.ProcSettings.LogicalProc":         "Enabled",
+		"BIOS.SysSecurity.TpmInfo":              "Type: 2.0 NTC",
+		"iDRAC.IPMILan.PrivLimit":               "4",
+		"iDRAC.IPMILan.Enable":                  "Disabled",
+		"iDRAC.VirtualConsole.PluginType":       "0",
+		"System.ThermalSettings.FanSpeedOffset": "Off",
+		"iDRAC.Users.2.Username":                "root",
+		"iDRAC.Users.2.Enable":                  "Disabled",
+		"iDRAC.Users.2.Privilege":               "0x1",
+		"iDRAC.Users.2.IpmiLanPrivilege":        "15",
+	}
+	for key, expected := range gets {
+		v, err := r.Get(ctx, key)
+		if err != nil {
+			t.Errorf("failed to get %s: %v", key, err)
+			continue
+		}
+		if v != expected {
+			t.Errorf("%s: expected %q, actual %q", key, expected, v)
+		}
+	}
+
+	if _, err := r.Get(ctx, "iDRAC.NoSuch.Key"); err == nil {
+		t.Error("Get should fail for unknown attributes")
+	}
+	if _, err := r.Get(ctx, "LifecycleController.LCAttributes.CollectSystemInventoryOnRestart"); err == nil {
+		t.Error("Get should fail for unsupported keys")
+	}
+
+	sets := []struct {
+		key   string
+		value string
+	}{
+		{"BIOS.ProcSettings.LogicalProc", "Disabled"},
+		{"iDRAC.IPMILan.PrivLimit", "3"},
+		{"iDRAC.IPMILan.Enable", "1"},
+		{"iDRAC.VirtualConsole.PluginType", "2"},
+		{"System.ThermalSettings.FanSpeedOffset", "0"},
+		{"iDRAC.Users.2.Enable", "Enabled"},
+		{"iDRAC.Users.2.Privilege", "0x1ff"},
+		{"iDRAC.Users.2.IpmiLanPrivilege", "4"},
+	}
+	for _, s := range sets {
+		if err := r.Set(ctx, s.key, s.value); err != nil {
+			t.Errorf("failed to set %s: %v", s.key, err)
+		}
+	}
+
+	// BIOS settings are pending.
+	if v, _ := r.Get(ctx, "BIOS.ProcSettings.LogicalProc"); v != "Enabled" {
+		t.Error("BIOS setting should be pending:", v)
+	}
+	if v := server.attribute("/redfish/v1/Systems/System.Embedded.1/Bios/Settings", "LogicalProc"); v != "Disabled" {
+		t.Error("unexpected pending BIOS setting:", v)
+	}
+
+	expected := map[string]string{
+		"iDRAC.IPMILan.PrivLimit":               "3",
+		"iDRAC.IPMILan.Enable":                  "Enabled",
+		"iDRAC.VirtualConsole.PluginType":       "2",
+		"System.ThermalSettings.FanSpeedOffset": "Low",
+		"iDRAC.Users.2.Enable":                  "Enabled",
+		"iDRAC.Users.2.Privilege":               "0x1ff",
+		"iDRAC.Users.2.IpmiLanPrivilege":        "4",
+	}
+	for key, value := range expected {
+		v, err := r.Get(ctx, key)
+		if err != nil {
+			t.Errorf("failed to get %s: %v", key, err)
+			continue
+		}
+		if v != value {
+			t.Errorf("%s: expected %q, actual %q", key, value, v)
+		}
+	}
+	if v := server.attribute("/redfish/v1/Managers/iDRAC.Embedded.1/Attributes", "IPMILan.1.PrivLimit"); v != "Operator" {
+		t.Error("unexpected Redfish value of PrivLimit:", v)
+	}
+
+	if err := r.Set(ctx, "iDRAC.Users.2.Enable", "maybe"); err == nil {
+		t.Error("Set should fail for invalid boolean")
+	}
+
+	// The new password of the user accessing Redfish is used afterward.
+	if err := r.SetSecret(ctx, "iDRAC.Users.2.Password", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get(ctx, "iDRAC.Info.Name"); err != nil {
+		t.Error("failed to access with the new password:", err)
+	}
+
+	out, err := r.JobQueueView(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "Status=Scheduled") {
+		t.Error("no jobs should be scheduled:", out)
+	}
+	if err := r.JobQueueCreate(ctx, "BIOS.Setup.1-1"); err != nil {
+		t.Fatal(err)
+	}
+	out, err = r.JobQueueView(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "[Job ID=JID_1]\nJob Name=Configure: BIOS.Setup.1-1\nStatus=Scheduled\n") {
+		t.Error("unexpected job queue:", out)
+	}
+	if err := r.JobQueueCreate(ctx, "NIC.Integrated.1-1-1"); err == nil {
+		t.Error("JobQueueCreate should fail for unsupported targets")
+	}
+
+	if _, err := r.Update(ctx, "BIOS.exe"); !errors.Is(err, ErrNotSupported) {
+		t.Error("Update should not be supported:", err)
+	}
+}
+
+func TestRedfishCache(t *testing.T) {
+	t.Parallel()
+
+	server := newRedfishServer()
+	ts := httptest.NewTLSServer(server)
+	defer ts.Close()
+
+	r, err := NewRedfish(ts.URL, "root", "calvin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	const attributes = "/redfish/v1/Managers/iDRAC.Embedded.1/Attributes"
+	for _, key := range []string{"iDRAC.Info.Name", "iDRAC.IPMILan.PrivLimit", "iDRAC.IPMILan.Enable"} {
+		if _, err := r.Get(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := server.getCount(attributes); n != 1 {
+		t.Error("attributes should be fetched once, but fetched", n, "times")
+	}
+
+	// A change is read back from iDRAC.
+	if err := r.Set(ctx, "iDRAC.IPMILan.Enable", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := r.Get(ctx, "iDRAC.IPMILan.Enable"); err != nil || v != "Enabled" {
+		t.Error("unexpected value after change:", v, err)
+	}
+	if n := server.getCount(attributes); n != 2 {
+		t.Error("attributes should be fetched again after change, but fetched", n, "times")
+	}
+}
+
+func TestRedfishRetry(t *testing.T) {
+	t.Parallel()
+
+	server := newRedfishServer()
+	ts := httptest.NewTLSServer(server)
+	defer ts.Close()
+
+	r, err := NewRedfish(ts.URL, "root", "calvin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.interval = time.Millisecond
+	ctx := context.Background()
+
+	server.fail(r.tries - 1)
+	if v, err := r.Get(ctx, "iDRAC.Info.Name"); err != nil || v != "iDRAC" {
+		t.Error("Get should succeed after retries:", v, err)
+	}
+
+	server.fail(r.tries)
+	if err := r.Set(ctx, "iDRAC.IPMILan.Enable", "1"); err == nil {
+		t.Error("Set should fail after retries")
+	}
+	server.fail(0)
+
+	// Client errors are not retried.
+	r.mu.Lock()
+	r.password = "wrong"
+	r.mu.Unlock()
+	before := server.requestCount()
+	if _, err := r.Get(ctx, "iDRAC.Users.2.Username"); err == nil {
+		t.Error("Get should fail with a wrong password")
+	}
+	if n := server.requestCount() - before; n != 1 {
+		t.Error("client errors should not be retried, but requested", n, "times")
+	}
+}
+
+func TestRedfishJobQueuePages(t *testing.T) {
+	t.Parallel()
+
+	const jobs = "/redfish/v1/Managers/iDRAC.Embedded.1/Jobs"
+	server := newRedfishServer()
+	server.resources[jobs] = map[string]interface{}{
+		"Members":                []interface{}{map[string]interface{}{"@odata.id": jobs + "/JID_1"}},
+		"Members@odata.nextLink": jobs + "?$skip=1",
+	}
+	server.resources[jobs+"?$skip=1"] = map[string]interface{}{
+		"Members": []interface{}{map[string]interface{}{"@odata.id": jobs + "/JID_2"}},
+	}
+	server.resources[jobs+"/JID_1"] = map[string]interface{}{
+		"Id":       "JID_1",
+		"Name":     "Configure: BIOS.Setup.1-1",
+		"JobState": "Completed",
+	}
+	server.resources[jobs+"/JID_2"] = map[string]interface{}{
+		"Id":       "JID_2",
+		"Name":     "Configure: BIOS.Setup.1-1",
+		"JobState": "Scheduled",
+	}
+	ts := httptest.NewTLSServer(server)
+	defer ts.Close()
+
+	r, err := NewRedfish(ts.URL, "root", "calvin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	out, err := r.JobQueueView(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "[Job ID=JID_1]\nJob Name=Configure: BIOS.Setup.1-1\nStatus=Completed\n") ||
+		!strings.Contains(out, "[Job ID=JID_2]\nJob Name=Configure: BIOS.Setup.1-1\nStatus=Scheduled\n") {
+		t.Error("jobs in the following page are not listed:", out)
+	}
+
+	// A loop of pages is an error rather than an incomplete list.
+	server.mu.Lock()
+	server.resources[jobs+"?$skip=1"]["Members@odata.nextLink"] = jobs
+	server.mu.Unlock()
+	if _, err := r.JobQueueView(ctx); err == nil {
+		t.Error("JobQueueView should fail for a loop of pages")
+	}
+}
@@ -34,3 +34,12 @@ func DetectVendor() (Vendor, error) {
 
 	return Unknown, errors.New("unknown vendor: " + vendor)
 }
+
+// DetectModel returns the product name of the server, e.g. "PowerEdge R640".
+func DetectModel() (string, error) {
+	data, err := os.ReadFile("/sys/devices/virtual/dmi/id/product_name")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,353 @@
+package cmd
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var anonymizeConfig struct {
+	keys  []string
+	keeps []string
+	salt  string
+}
+
+// defaultAnonymizeKeys are the patterns of keys whose values identify a machine.
+// They are anchored at the end so that they do not match keys of settings such as
+// "Serial.1.Enable", whose values "Enabled" and "Disabled" appear everywhere.
+var defaultAnonymizeKeys = []string{
+	"(?i)serialnumber$",
+	"(?i)servicetag$",
+	"(?i)assettag$",
+	"(?i)^sku$",
+	"(?i)nodeid$",
+	"(?i)hostname$",
+	"(?i)fqdn$",
+	"(?i)domainname$",
+}
+
+// defaultKeepKeys are the patterns of keys whose values look like addresses but are not.
+var defaultKeepKeys = []string{
+	"(?i)version",
+}
+
+// anonymizeMinLength is the minimum length of values of matched keys to be replaced in other strings.
+// Shorter values are likely to appear by chance.
+const anonymizeMinLength = 4
+
+var (
+	macRegexp  = regexp.MustCompile(`\b[0-9A-Fa-f]{2}([:-])[0-9A-Fa-f]{2}(?:[:-][0-9A-Fa-f]{2}){4}\b`)
+	guidRegexp = regexp.MustCompile(`\b[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}\b`)
+	ipv4Regexp = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])\b`)
+	ipv6Regexp = regexp.MustCompile(`(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`)
+)
+
+// anonymizeCmd represents the anonymize command
+var anonymizeCmd = &cobra.Command{
+	Use:   "anonymize INPUT_FILE",
+	Short: "replace identifiers in Redfish data with pseudonyms",
+	Long: `Replace identifiers in Redfish data with pseudonyms.
+
+It takes a JSON file that was dumped by "collector show" command or an archive
+created by "collector capture" command, and outputs
+the data whose serial numbers, host names, MAC addresses, IP addresses and
+GUIDs are replaced.  The same identifier is always replaced with the same
+pseudonym, so references between pages are kept.`,
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keys, err := compileKeyPatterns(append(defaultAnonymizeKeys, anonymizeConfig.keys...))
+		if err != nil {
+			return err
+		}
+		keeps, err := compileKeyPatterns(append(defaultKeepKeys, anonymizeConfig.keeps...))
+		if err != nil {
+			return err
+		}
+
+		salt := []byte(anonymizeConfig.salt)
+		if len(salt) == 0 {
+			salt = make([]byte, 32)
+			if _, err := rand.Read(salt); err != nil {
+				return err
+			}
+		}
+
+		well.Go(func(ctx context.Context) error {
+			input, err := loadInput(args[0])
+			if err != nil {
+				return err
+			}
+
+			a := newAnonymizer(salt, keys, keeps)
+			out, err := json.MarshalIndent(a.anonymize(input), "", "    ")
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(append(out, '\n'))
+			return err
+		})
+
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+// compileKeyPatterns compiles patterns into one regular expression.
+// Each pattern is grouped so that its flags do not affect the others.
+func compileKeyPatterns(patterns []string) (*regexp.Regexp, error) {
+	groups := make([]string, len(patterns))
+	for i, p := range patterns {
+		groups[i] = "(?:" + p + ")"
+	}
+	return regexp.Compile(strings.Join(groups, "|"))
+}
+
+// anonymizer replaces identifiers with pseudonyms derived from HMAC-SHA256 of them.
+type anonymizer struct {
+	salt     []byte
+	keys     *regexp.Regexp
+	keeps    *regexp.Regexp
+	replacer *strings.Replacer
+}
+
+func newAnonymizer(salt []byte, keys, keeps *regexp.Regexp) *anonymizer {
+	return &anonymizer{
+		salt:  salt,
+		keys:  keys,
+		keeps: keeps,
+	}
+}
+
+// anonymize returns a copy of the Redfish data whose identifiers are replaced.
+// Paths of pages are also anonymized so that they match anonymized "@odata.id" values.
+func (a *anonymizer) anonymize(input map[string]interface{}) map[string]interface{} {
+	values := make(map[string]bool)
+	for _, page := range input {
+		a.findValues(page, values)
+	}
+	a.replacer = a.newReplacer(values)
+
+	result := make(map[string]interface{})
+	for path, page := range input {
+		result[a.anonymizeString(path)] = a.anonymizeValue("", page)
+	}
+	return result
+}
+
+// findValues collects the string values of the matched keys, including those in arrays.
+func (a *anonymizer) findValues(data interface{}, values map[string]bool) {
+	switch data := data.(type) {
+	case map[string]interface{}:
+		for k, v := range data {
+			if a.keys.MatchString(k) && !a.keeps.MatchString(k) {
+				if a.collectStrings(v, values) {
+					continue
+				}
+			}
+			a.findValues(v, values)
+		}
+	case []interface{}:
+		for _, v := range data {
+			a.findValues(v, values)
+		}
+	}
+}
+
+// collectStrings adds v to values if v is a string or an array of strings.
+// It returns false if v is not.
+func (a *anonymizer) collectStrings(v interface{}, values map[string]bool) bool {
+	switch v := v.(type) {
+	case string:
+		values[v] = true
+		return true
+	case []interface{}:
+		for _, e := range v {
+			if _, ok := e.(string); !ok {
+				return false
+			}
+		}
+		for _, e := range v {
+			values[e.(string)] = true
+		}
+		return true
+	}
+	return false
+}
+
+// newReplacer returns a replacer which replaces the values of the matched keys in any string.
+func (a *anonymizer) newReplacer(values map[string]bool) *strings.Replacer {
+	var olds []string
+	for v := range values {
+		if len(v) >= anonymizeMinLength {
+			olds = append(olds, v)
+		}
+	}
+	// strings.Replacer tries the pairs in order, so try longer values first.
+	sort.Slice(olds, func(i, j int) bool {
+		if len(olds[i]) != len(olds[j]) {
+			return len(olds[i]) > len(olds[j])
+		}
+		return olds[i] < olds[j]
+	})
+
+	oldnew := make([]string, 0, len(olds)*2)
+	for _, v := range olds {
+		oldnew = append(oldnew, v, a.pseudonymText(v))
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+func (a *anonymizer) anonymizeValue(key string, data interface{}) interface{} {
+	switch data := data.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{})
+		for k, v := range data {
+			result[k] = a.anonymizeValue(k, v)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(data))
+		for i, v := range data {
+			result[i] = a.anonymizeValue(key, v)
+		}
+		return result
+	case string:
+		if a.keeps.MatchString(key) {
+			return data
+		}
+		if a.keys.MatchString(key) {
+			return a.pseudonymText(data)
+		}
+		return a.anonymizeString(data)
+	}
+	return data
+}
+
+// anonymizeString replaces the values of the matched keys and the detected addresses in s.
+func (a *anonymizer) anonymizeString(s string) string {
+	s = a.replacer.Replace(s)
+	s = macRegexp.ReplaceAllStringFunc(s, a.pseudonymMAC)
+	s = guidRegexp.ReplaceAllStringFunc(s, a.pseudonymGUID)
+	s = ipv4Regexp.ReplaceAllStringFunc(s, a.pseudonymIPv4)
+	s = ipv6Regexp.ReplaceAllStringFunc(s, a.pseudonymIPv6)
+	return s
+}
+
+// digest returns HMAC-SHA256 of s keyed by the salt.
+// The kind separates pseudonyms of the same string in different formats.
+func (a *anonymizer) digest(kind, s string) []byte {
+	mac := hmac.New(sha256.New, a.salt)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+// pseudonymText returns a hexadecimal string of the same length as s.
+// It is in upper case unless s contains lower case letters.
+func (a *anonymizer) pseudonymText(s string) string {
+	if s == "" {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; sb.Len() < len(s); i++ {
+		sb.WriteString(hex.EncodeToString(a.digest(fmt.Sprintf("text%d", i), s)))
+	}
+	p := sb.String()[:len(s)]
+	if strings.ToUpper(s) == s {
+		p = strings.ToUpper(p)
+	}
+	return p
+}
+
+// pseudonymMAC returns a locally administered unicast MAC address in the same notation as s.
+func (a *anonymizer) pseudonymMAC(s string) string {
+	d := a.digest("mac", strings.ToLower(strings.ReplaceAll(s, "-", ":")))
+	d[0] = d[0]&0xfc | 0x02
+
+	p := net.HardwareAddr(d[:6]).String()
+	if strings.Contains(s, "-") {
+		p = strings.ReplaceAll(p, ":", "-")
+	}
+	if strings.ToUpper(s) == s {
+		p = strings.ToUpper(p)
+	}
+	return p
+}
+
+// pseudonymGUID returns a random-based GUID in the same case as s.
+func (a *anonymizer) pseudonymGUID(s string) string {
+	d := a.digest("guid", strings.ToLower(s))
+	d[6] = d[6]&0x0f | 0x40
+	d[8] = d[8]&0x3f | 0x80
+
+	h := hex.EncodeToString(d[:16])
+	p := h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+	if strings.ToUpper(s) == s {
+		p = strings.ToUpper(p)
+	}
+	return p
+}
+
+// pseudonymIPv4 returns an address in 10.0.0.0/8.
+// Unspecified, loopback and netmask-like addresses are kept as they are.
+func (a *anonymizer) pseudonymIPv4(s string) string {
+	ip := net.ParseIP(s).To4()
+	if ip == nil || ip.IsLoopback() {
+		return s
+	}
+	if _, bits := net.IPMask(ip).Size(); bits != 0 {
+		return s
+	}
+
+	d := a.digest("ipv4", s)
+	return net.IPv4(10, d[0], d[1], d[2]).String()
+}
+
+// pseudonymIPv6 returns an address in fd00::/8.
+// Strings which are not IPv6 addresses, and unspecified and loopback addresses are kept as they are.
+// Link-local addresses keep their prefix.
+func (a *anonymizer) pseudonymIPv6(s string) string {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.To4() != nil || ip.IsUnspecified() || ip.IsLoopback() {
+		return s
+	}
+
+	d := a.digest("ipv6", ip.String())
+	p := make(net.IP, net.IPv6len)
+	p[0] = 0xfd
+	copy(p[1:], d[:net.IPv6len-1])
+	if ip.IsLinkLocalUnicast() {
+		// Keep the prefix so that the scope of the address is kept.
+		copy(p, ip[:8])
+	}
+	if binary.BigEndian.Uint64(ip[8:]) == 0 {
+		// Keep prefixes, e.g. "2001:db8::", as prefixes.
+		for i := 8; i < net.IPv6len; i++ {
+			p[i] = 0
+		}
+	}
+	return p.String()
+}
+
+func init() {
+	rootCmd.AddCommand(anonymizeCmd)
+	anonymizeCmd.Flags().StringSliceVar(&anonymizeConfig.keys, "key", nil, "pattern of keys whose values are replaced in addition to the default ones")
+	anonymizeCmd.Flags().StringSliceVar(&anonymizeConfig.keeps, "keep", nil, "pattern of keys whose values are kept in addition to the default ones")
+	anonymizeCmd.Flags().StringVar(&anonymizeConfig.salt, "salt", "", "secret to derive pseudonyms; give the same salt to get the same pseudonyms across runs (default is random)")
+}
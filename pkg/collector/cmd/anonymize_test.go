@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAnonymize(t *testing.T) {
+	t.Parallel()
+
+	inputJSON := `
+{
+    "/redfish/v1/Systems/System.Embedded.1": {
+        "@odata.id": "/redfish/v1/Systems/System.Embedded.1",
+        "SerialNumber": "CN7475150B0001",
+        "SKU": "ABC1234",
+        "HostName": "rack0-cs1",
+        "UUID": "4c4c4544-0042-5110-8030-b4c04f4a3132",
+        "BiosVersion": "2.10.10",
+        "Oem": {
+            "Dell": {
+                "DellSystem": {
+                    "ChassisServiceTag": "ABC1234",
+                    "Description": "ABC1234 at rack0-cs1.example.com"
+                }
+            }
+        }
+    },
+    "/redfish/v1/Managers/iDRAC.Embedded.1/EthernetInterfaces/NIC.1": {
+        "@odata.id": "/redfish/v1/Managers/iDRAC.Embedded.1/EthernetInterfaces/NIC.1",
+        "MACAddress": "D0:94:66:12:34:56",
+        "PermanentMACAddress": "d0-94-66-12-34-56",
+        "IPv4Addresses": [
+            {
+                "Address": "192.168.10.20",
+                "SubnetMask": "255.255.255.0",
+                "Gateway": "0.0.0.0"
+            }
+        ],
+        "IPv6Addresses": [
+            {
+                "Address": "2001:db8::1234",
+                "PrefixLength": 64
+            },
+            {
+                "Address": "::"
+            }
+        ],
+        "FirmwareVersion": "4.40.10.20",
+        "Status": {
+            "Health": "OK"
+        }
+    },
+    "/redfish/v1/Chassis/ABC1234": {
+        "@odata.id": "/redfish/v1/Chassis/ABC1234"
+    }
+}
+`
+	var input map[string]interface{}
+	if err := json.Unmarshal([]byte(inputJSON), &input); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := compileKeyPatterns(defaultAnonymizeKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keeps, err := compileKeyPatterns(defaultKeepKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newAnonymizer([]byte("salt"), keys, keeps)
+	result := a.anonymize(input)
+	out, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{
+		"CN7475150B0001",
+		"ABC1234",
+		"rack0-cs1",
+		"4c4c4544-0042-5110-8030-b4c04f4a3132",
+		"D0:94:66:12:34:56",
+		"d0-94-66-12-34-56",
+		"192.168.10.20",
+		"2001:db8::1234",
+	} {
+		if strings.Contains(string(out), secret) {
+			t.Error("anonymized data contains", secret)
+		}
+	}
+
+	for _, kept := range []string{
+		`"2.10.10"`,
+		`"4.40.10.20"`,
+		`"255.255.255.0"`,
+		`"0.0.0.0"`,
+		`"::"`,
+		`"OK"`,
+		`"/redfish/v1/Systems/System.Embedded.1"`,
+	} {
+		if !strings.Contains(string(out), kept) {
+			t.Error("anonymized data does not contain", kept)
+		}
+	}
+
+	system := result["/redfish/v1/Systems/System.Embedded.1"].(map[string]interface{})
+	sku := system["SKU"].(string)
+	if len(sku) != len("ABC1234") || strings.ToUpper(sku) != sku {
+		t.Error("pseudonym does not keep the format:", sku)
+	}
+	dellSystem := system["Oem"].(map[string]interface{})["Dell"].(map[string]interface{})["DellSystem"].(map[string]interface{})
+	if dellSystem["ChassisServiceTag"] != sku {
+		t.Error("same values are replaced with different pseudonyms:", dellSystem["ChassisServiceTag"], sku)
+	}
+	if !strings.HasPrefix(dellSystem["Description"].(string), sku+" at ") {
+		t.Error("value in a string is not replaced with the pseudonym:", dellSystem["Description"])
+	}
+
+	chassisPath := "/redfish/v1/Chassis/" + sku
+	chassis, ok := result[chassisPath].(map[string]interface{})
+	if !ok {
+		t.Fatal("path is not anonymized consistently")
+	}
+	if chassis["@odata.id"] != chassisPath {
+		t.Error("@odata.id does not match the anonymized path:", chassis["@odata.id"])
+	}
+
+	nic := result["/redfish/v1/Managers/iDRAC.Embedded.1/EthernetInterfaces/NIC.1"].(map[string]interface{})
+	mac := nic["MACAddress"].(string)
+	permanentMAC := nic["PermanentMACAddress"].(string)
+	if strings.ToLower(strings.ReplaceAll(permanentMAC, "-", ":")) != strings.ToLower(mac) {
+		t.Error("same MAC addresses in different notations are replaced differently:", mac, permanentMAC)
+	}
+	if strings.ToUpper(mac) != mac || !strings.Contains(permanentMAC, "-") {
+		t.Error("pseudonyms of MAC addresses do not keep the notation:", mac, permanentMAC)
+	}
+	address := nic["IPv4Addresses"].([]interface{})[0].(map[string]interface{})["Address"].(string)
+	if !strings.HasPrefix(address, "10.") {
+		t.Error("unexpected pseudonym of IPv4 address:", address)
+	}
+	address6 := nic["IPv6Addresses"].([]interface{})[0].(map[string]interface{})["Address"].(string)
+	if !strings.HasPrefix(address6, "fd") {
+		t.Error("unexpected pseudonym of IPv6 address:", address6)
+	}
+
+	// The same salt gives the same pseudonyms.
+	again := newAnonymizer([]byte("salt"), keys, keeps).anonymize(input)
+	out2, err := json.Marshal(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(out2) {
+		t.Error("pseudonyms are not stable")
+	}
+}
+
+func TestAnonymizeSettingKeys(t *testing.T) {
+	t.Parallel()
+
+	inputJSON := `
+{
+    "/redfish/v1/Systems/System.Embedded.1/Bios": {
+        "@odata.id": "/redfish/v1/Systems/System.Embedded.1/Bios",
+        "Attributes": {
+            "SerialComm": "OnConRedirAuto",
+            "SerialPortAddress": "Serial1Com2Serial2Com1",
+            "SystemServiceTag": "ABC1234"
+        }
+    },
+    "/redfish/v1/Managers/iDRAC.Embedded.1/Attributes": {
+        "@odata.id": "/redfish/v1/Managers/iDRAC.Embedded.1/Attributes",
+        "Attributes": {
+            "Serial.1.Enable": "Enabled",
+            "SerialCapture.1.Enable": "Disabled",
+            "NIC.1.DNSRacName": "rack0-cs1-idrac"
+        },
+        "Aliases": ["rack0-cs1", "cs1"]
+    },
+    "/redfish/v1/Systems/System.Embedded.1": {
+        "@odata.id": "/redfish/v1/Systems/System.Embedded.1",
+        "Description": "rack0-cs1 in rack0",
+        "Status": {
+            "State": "Enabled"
+        },
+        "TrustedModules": [
+            {
+                "Status": {
+                    "State": "Disabled"
+                }
+            }
+        ]
+    }
+}
+`
+	var input map[string]interface{}
+	if err := json.Unmarshal([]byte(inputJSON), &input); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := compileKeyPatterns(append(defaultAnonymizeKeys, "(?i)^aliases$"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keeps, err := compileKeyPatterns(defaultKeepKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := newAnonymizer([]byte("salt"), keys, keeps).anonymize(input)
+	out, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Values of settings whose keys contain "Serial" are not identifiers.
+	for _, kept := range []string{
+		`"State":"Enabled"`,
+		`"State":"Disabled"`,
+		`"Serial.1.Enable":"Enabled"`,
+		`"SerialCapture.1.Enable":"Disabled"`,
+		`"SerialComm":"OnConRedirAuto"`,
+	} {
+		if !strings.Contains(string(out), kept) {
+			t.Error("anonymized data does not contain", kept)
+		}
+	}
+
+	// Strings in an array of a matched key are also replaced in other strings.
+	for _, secret := range []string{"ABC1234", "rack0-cs1"} {
+		if strings.Contains(string(out), secret) {
+			t.Error("anonymized data contains", secret)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/setup-hw/redfish"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+const (
+	captureMetadataFile = "capture.json"
+	captureBodyDir      = "bodies"
+	gzipMagic           = "\x1f\x8b"
+)
+
+var captureConfig struct {
+	output string
+}
+
+// captureCmd represents the capture command
+var captureCmd = &cobra.Command{
+	Use:   "capture",
+	Short: "record requests and responses of Redfish traversal into an archive",
+	Long: `Record requests and responses of Redfish traversal into an archive.
+
+The archive is a gzipped tar file containing the URL, status code, headers,
+latency and body of every request, as well as the Redfish version and vendor.
+It can be given to other commands as --input-file.`,
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if captureConfig.output == "" {
+			return errors.New("--output is mandatory")
+		}
+
+		rule, err := loadBaseRule(rootConfig.baseRuleFile)
+		if err != nil {
+			return err
+		}
+
+		well.Go(func(ctx context.Context) error {
+			recorder := new(exchangeRecorder)
+			client, err := newRedfishClient(recorder.record)
+			if err != nil {
+				return err
+			}
+			collected := client.Traverse(ctx, rule)
+
+			version, vendor := serviceRootInfo(&collected, rule)
+			exchanges := recorder.get()
+			log.Info("captured Redfish data", map[string]interface{}{
+				"requests":        len(exchanges),
+				"pages":           len(collected.Data()),
+				"redfish_version": version,
+				"vendor":          vendor,
+			})
+
+			f, err := os.Create(captureConfig.output)
+			if err != nil {
+				return err
+			}
+			if err := writeCaptureArchive(f, exchanges, version, vendor); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		})
+
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+// exchangeRecorder keeps exchanges recorded concurrently.
+type exchangeRecorder struct {
+	mu        sync.Mutex
+	exchanges []*redfish.Exchange
+}
+
+func (r *exchangeRecorder) record(ex *redfish.Exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exchanges = append(r.exchanges, ex)
+}
+
+// get returns the recorded exchanges in the order they started.
+func (r *exchangeRecorder) get() []*redfish.Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	exchanges := append([]*redfish.Exchange(nil), r.exchanges...)
+	sort.SliceStable(exchanges, func(i, j int) bool {
+		return exchanges[i].Start.Before(exchanges[j].Start)
+	})
+	return exchanges
+}
+
+// serviceRootInfo returns the Redfish version and the vendor from the service root.
+// The vendor is taken from the Vendor property, or from the key of the Oem property if absent.
+func serviceRootInfo(collected *redfish.Collected, rule *redfish.CollectRule) (string, string) {
+	root, ok := collected.Data()[rule.TraverseRule.Root]
+	if !ok {
+		return "", ""
+	}
+
+	version, _ := root.Path("RedfishVersion").Data().(string)
+	vendor, _ := root.Path("Vendor").Data().(string)
+	if vendor == "" {
+		if oem, err := root.Path("Oem").ChildrenMap(); err == nil {
+			var keys []string
+			for k := range oem {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			if len(keys) != 0 {
+				vendor = keys[0]
+			}
+		}
+	}
+	return version, vendor
+}
+
+// captureMetadata is the content of capture.json in a capture archive.
+type captureMetadata struct {
+	RedfishVersion string          `json:"redfishVersion"`
+	Vendor         string          `json:"vendor"`
+	Exchanges      []*captureEntry `json:"exchanges"`
+}
+
+// captureEntry is a recorded exchange.  Its body is stored in a separate file named by Body.
+type captureEntry struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Start   time.Time   `json:"start"`
+	Latency string      `json:"latency"`
+	Status  int         `json:"status,omitempty"`
+	Header  http.Header `json:"header,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// writeCaptureArchive writes exchanges into w as a gzipped tar archive.
+func writeCaptureArchive(w io.Writer, exchanges []*redfish.Exchange, version, vendor string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	now := time.Now()
+
+	writeFile := func(name string, data []byte) error {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+
+	metadata := &captureMetadata{
+		RedfishVersion: version,
+		Vendor:         vendor,
+		Exchanges:      make([]*captureEntry, len(exchanges)),
+	}
+	for i, ex := range exchanges {
+		entry := &captureEntry{
+			Method:  ex.Method,
+			URL:     ex.URL,
+			Start:   ex.Start,
+			Latency: ex.Latency.String(),
+			Status:  ex.Status,
+			Header:  ex.Header,
+		}
+		if ex.Err != nil {
+			entry.Error = ex.Err.Error()
+		}
+		if len(ex.Body) != 0 {
+			entry.Body = fmt.Sprintf("%s/%06d", captureBodyDir, i)
+			if err := writeFile(entry.Body, ex.Body); err != nil {
+				return err
+			}
+		}
+		metadata.Exchanges[i] = entry
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(captureMetadataFile, data); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// readCaptureArchive reads a capture archive and returns Redfish data in the same format as "collector show".
+// Pages are taken from successful GET responses.
+func readCaptureArchive(r io.Reader) (map[string]interface{}, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[hdr.Name] = data
+	}
+
+	data, ok := files[captureMetadataFile]
+	if !ok {
+		return nil, fmt.Errorf("%s is not found in the archive", captureMetadataFile)
+	}
+	var metadata captureMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{})
+	for _, entry := range metadata.Exchanges {
+		if entry.Method != http.MethodGet || entry.Status != http.StatusOK || entry.Body == "" {
+			continue
+		}
+		u, err := url.Parse(entry.URL)
+		if err != nil {
+			return nil, err
+		}
+		body, ok := files[entry.Body]
+		if !ok {
+			return nil, fmt.Errorf("%s is not found in the archive", entry.Body)
+		}
+
+		var page interface{}
+		if err := json.Unmarshal(body, &page); err != nil {
+			log.Warn("skip malformed page in the archive", map[string]interface{}{
+				"url":       entry.URL,
+				log.FnError: err,
+			})
+			continue
+		}
+		path := u.EscapedPath()
+		if u.RawQuery != "" {
+			path += "?" + u.RawQuery
+		}
+		result[path] = page
+	}
+
+	mergeCollectionPages(result)
+	return result, nil
+}
+
+// mergeCollectionPages merges the following pages of collections into their first pages
+// and removes them, as live traversal does when it follows Members@odata.nextLink.
+func mergeCollectionPages(pages map[string]interface{}) {
+	collections := make(map[string]map[string]interface{})
+	linked := make(map[string]bool)
+	for path, page := range pages {
+		collection, ok := page.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if link, ok := redfish.NextLink(collection); ok {
+			collections[path] = collection
+			linked[link] = true
+		}
+	}
+
+	// The first pages are those which are not linked as next pages.
+	// Pages in a chain linking back to its first page are all linked, so one of
+	// them is taken as the first page, preferring a path without a query string.
+	paths := make([]string, 0, len(collections))
+	for path := range collections {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if linked[paths[i]] != linked[paths[j]] {
+			return !linked[paths[i]]
+		}
+		qi, qj := strings.Contains(paths[i], "?"), strings.Contains(paths[j], "?")
+		if qi != qj {
+			return !qi
+		}
+		return paths[i] < paths[j]
+	})
+
+	first := make(map[string]bool)
+	merged := make(map[string]bool)
+	for _, path := range paths {
+		if merged[path] {
+			continue
+		}
+		first[path] = true
+		links := redfish.MergeCollectionPages(path, collections[path], func(link string) (map[string]interface{}, bool) {
+			if first[link] || merged[link] {
+				return nil, false
+			}
+			next, ok := pages[link].(map[string]interface{})
+			return next, ok
+		})
+		for _, link := range links {
+			merged[link] = true
+		}
+	}
+
+	for link := range merged {
+		delete(pages, link)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(captureCmd)
+	captureCmd.Flags().StringVarP(&captureConfig.output, "output", "o", "", "archive file to be created, e.g. bmc.tar.gz")
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/setup-hw/redfish"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCaptureArchive(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	exchanges := []*redfish.Exchange{
+		{
+			Method:  http.MethodPost,
+			URL:     "https://192.168.0.1/redfish/v1/SessionService/Sessions",
+			Start:   start,
+			Latency: 10 * time.Millisecond,
+			Status:  http.StatusCreated,
+			Header:  http.Header{"Location": []string{"/redfish/v1/SessionService/Sessions/1"}},
+		},
+		{
+			Method:  http.MethodGet,
+			URL:     "https://192.168.0.1/redfish/v1",
+			Start:   start.Add(time.Millisecond),
+			Latency: 20 * time.Millisecond,
+			Status:  http.StatusOK,
+			Body:    []byte(`{"@odata.id": "/redfish/v1", "RedfishVersion": "1.6.0"}`),
+		},
+		{
+			Method:  http.MethodGet,
+			URL:     "https://192.168.0.1/redfish/v1/Chassis/Enclosure.Internal.0-1:RAID.Slot.1-1",
+			Start:   start.Add(2 * time.Millisecond),
+			Latency: 30 * time.Millisecond,
+			Status:  http.StatusOK,
+			Body:    []byte(`{"@odata.id": "/redfish/v1/Chassis/Enclosure.Internal.0-1:RAID.Slot.1-1"}`),
+		},
+		{
+			Method:  http.MethodGet,
+			URL:     "https://192.168.0.1/redfish/v1/Systems",
+			Start:   start.Add(3 * time.Millisecond),
+			Latency: 40 * time.Millisecond,
+			Status:  http.StatusInternalServerError,
+			Body:    []byte(`{"error": {}}`),
+		},
+		{
+			Method:  http.MethodGet,
+			URL:     "https://192.168.0.1/redfish/v1/Chassis",
+			Start:   start.Add(3 * time.Millisecond),
+			Latency: 10 * time.Millisecond,
+			Status:  http.StatusOK,
+			Body:    []byte(`{"Members": [{"@odata.id": "/redfish/v1/Chassis/1"}], "Members@odata.nextLink": "https://192.168.0.1/redfish/v1/Chassis?$skip=1"}`),
+		},
+		{
+			Method:  http.MethodGet,
+			URL:     "https://192.168.0.1/redfish/v1/Chassis?$skip=1",
+			Start:   start.Add(3 * time.Millisecond),
+			Latency: 10 * time.Millisecond,
+			Status:  http.StatusOK,
+			Body:    []byte(`{"Members": [{"@odata.id": "/redfish/v1/Chassis/2"}], "Members@odata.nextLink": "/redfish/v1/Chassis?$skip=2"}`),
+		},
+		{
+			Method:  http.MethodGet,
+			URL:     "https://192.168.0.1/redfish/v1/Chassis?$skip=2",
+			Start:   start.Add(3 * time.Millisecond),
+			Latency: 10 * time.Millisecond,
+			Status:  http.StatusOK,
+			Body:    []byte(`{"Members": [{"@odata.id": "/redfish/v1/Chassis/3"}]}`),
+		},
+		{
+			Method:  http.MethodGet,
+			URL:     "https://192.168.0.1/redfish/v1/Managers",
+			Start:   start.Add(4 * time.Millisecond),
+			Latency: time.Second,
+			Err:     errors.New("timeout"),
+		},
+	}
+
+	archive := filepath.Join(t.TempDir(), "bmc.tar.gz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCaptureArchive(f, exchanges, "1.6.0", "Dell"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	input, err := loadInput(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"/redfish/v1": map[string]interface{}{
+			"@odata.id":      "/redfish/v1",
+			"RedfishVersion": "1.6.0",
+		},
+		"/redfish/v1/Chassis/Enclosure.Internal.0-1:RAID.Slot.1-1": map[string]interface{}{
+			"@odata.id": "/redfish/v1/Chassis/Enclosure.Internal.0-1:RAID.Slot.1-1",
+		},
+		// The following pages of the collection are merged into the first page, as live traversal does.
+		"/redfish/v1/Chassis": map[string]interface{}{
+			"Members": []interface{}{
+				map[string]interface{}{"@odata.id": "/redfish/v1/Chassis/1"},
+				map[string]interface{}{"@odata.id": "/redfish/v1/Chassis/2"},
+				map[string]interface{}{"@odata.id": "/redfish/v1/Chassis/3"},
+			},
+		},
+	}
+	if !cmp.Equal(input, expected) {
+		t.Error("unexpected pages were loaded:", cmp.Diff(expected, input))
+	}
+}
+
+func TestLoadInputJSON(t *testing.T) {
+	t.Parallel()
+
+	input, err := loadInput("../../../testdata/fakebmc_dump.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := input["/redfish/v1"]; !ok {
+		t.Error("pages were not loaded from JSON dump")
+	}
+}
+
+func TestMergeCollectionPagesCycle(t *testing.T) {
+	t.Parallel()
+
+	// The last page links back to the first page.
+	pages := map[string]interface{}{
+		"/redfish/v1/Systems": map[string]interface{}{
+			"Members":                []interface{}{"1"},
+			"Members@odata.nextLink": "/redfish/v1/Systems?$skip=1",
+		},
+		"/redfish/v1/Systems?$skip=1": map[string]interface{}{
+			"Members":                []interface{}{"2"},
+			"Members@odata.nextLink": "https://192.168.0.1/redfish/v1/Systems",
+		},
+	}
+	mergeCollectionPages(pages)
+
+	expected := map[string]interface{}{
+		"/redfish/v1/Systems": map[string]interface{}{
+			"Members": []interface{}{"1", "2"},
+		},
+	}
+	if !cmp.Equal(pages, expected) {
+		t.Error("cyclic pages were not merged:", cmp.Diff(expected, pages))
+	}
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/cybozu-go/setup-hw/gabs"
+	"github.com/cybozu-go/setup-hw/redfish"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var coverageConfig struct {
+	inputFile string
+	ruleFile  string
+	fail      bool
+}
+
+// coverageCmd represents the coverage command
+var coverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "report coverage of Redfish data by a collection rule",
+	Long: `Report coverage of Redfish data by a collection rule.
+
+It reports metric rules whose paths matched no page, property rules whose
+pointers resolved to nothing or failed conversion, and pages having
+Status/Health which no metric rule matches.`,
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ruleFile := coverageConfig.ruleFile
+		if ruleFile == "" {
+			ruleFile = rootConfig.baseRuleFile
+		}
+		if ruleFile == "" {
+			return errors.New("--rule is mandatory")
+		}
+
+		well.Go(func(ctx context.Context) error {
+			collected, err := collectOrLoad(ctx, coverageConfig.inputFile, ruleFile)
+			if err != nil {
+				return err
+			}
+
+			report := ruleCoverage(collected)
+			if err := report.write(os.Stdout); err != nil {
+				return err
+			}
+			if coverageConfig.fail && !report.complete() {
+				return errors.New("the rule does not fully cover the data")
+			}
+			return nil
+		})
+
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+// coverageReport is the coverage of Redfish data by a collection rule.
+type coverageReport struct {
+	propertyRules    int
+	unmatchedPaths   []string
+	notFound         []redfish.PropertyMatch
+	conversionErrors []redfish.PropertyMatch
+	uncoveredPages   []string
+}
+
+func ruleCoverage(collected *redfish.Collected) *coverageReport {
+	report := new(coverageReport)
+
+	rule := collected.Rule()
+	for _, mr := range rule.MetricRules {
+		matched := false
+		for path := range collected.Data() {
+			if ok, _ := mr.MatchPath(path); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report.unmatchedPaths = append(report.unmatchedPaths, mr.Path)
+		}
+	}
+
+	_, matches := collected.Metrics()
+	report.propertyRules = len(matches)
+	for _, m := range matches {
+		if m.NotFound > 0 {
+			report.notFound = append(report.notFound, m)
+		}
+		if m.ConversionErrors > 0 {
+			report.conversionErrors = append(report.conversionErrors, m)
+		}
+	}
+
+	for path, page := range collected.Data() {
+		if !hasHealth(page) {
+			continue
+		}
+		covered := false
+		for _, mr := range rule.MetricRules {
+			if ok, _ := mr.MatchPath(path); ok {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			report.uncoveredPages = append(report.uncoveredPages, path)
+		}
+	}
+	sort.Strings(report.uncoveredPages)
+
+	return report
+}
+
+// hasHealth returns whether the data has Status/Health at any depth.
+func hasHealth(current *gabs.Container) bool {
+	if childrenMap, err := current.ChildrenMap(); err == nil {
+		for k, v := range childrenMap {
+			if k == "Status" && v.Exists("Health") {
+				return true
+			}
+			if hasHealth(v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if children, err := current.Children(); err == nil {
+		for _, child := range children {
+			if hasHealth(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// complete returns whether the rule covers the data without any problem.
+func (r *coverageReport) complete() bool {
+	return len(r.unmatchedPaths) == 0 && len(r.notFound) == 0 &&
+		len(r.conversionErrors) == 0 && len(r.uncoveredPages) == 0
+}
+
+func (r *coverageReport) write(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("Paths matching no page: %d\n", len(r.unmatchedPaths))
+	for _, p := range r.unmatchedPaths {
+		printf("    %s\n", p)
+	}
+
+	printf("Pointers resolving to nothing: %d\n", len(r.notFound))
+	for _, m := range r.notFound {
+		printf("    %s %s (%s): not found %d times in %d pages\n", m.Path, m.Pointer, m.Name, m.NotFound, m.Pages)
+	}
+
+	printf("Pointers failing conversion: %d\n", len(r.conversionErrors))
+	for _, m := range r.conversionErrors {
+		printf("    %s %s (%s): failed %d times in %d pages\n", m.Path, m.Pointer, m.Name, m.ConversionErrors, m.Pages)
+	}
+
+	printf("Pages with Status/Health but no rule: %d\n", len(r.uncoveredPages))
+	for _, p := range r.uncoveredPages {
+		printf("    %s\n", p)
+	}
+
+	printf("Property rules: %d\n", r.propertyRules)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(coverageCmd)
+	coverageCmd.Flags().StringVar(&coverageConfig.inputFile, "input-file", "", "pre-collected Redfish data")
+	coverageCmd.Flags().StringVar(&coverageConfig.ruleFile, "rule", "", "collection rule file (default is the file given by --base-rule)")
+	coverageCmd.Flags().BoolVar(&coverageConfig.fail, "fail", false, "exit with non-zero status if the rule does not fully cover the data")
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/cybozu-go/setup-hw/gabs"
+	"github.com/cybozu-go/setup-hw/redfish"
+)
+
+func TestRuleCoverage(t *testing.T) {
+	rule := &redfish.CollectRule{
+		TraverseRule: redfish.TraverseRule{Root: "/redfish/v1"},
+		MetricRules: []*redfish.MetricRule{
+			{
+				Path: "/redfish/v1/Chassis/{chassis}",
+				PropertyRules: []*redfish.PropertyRule{
+					{Pointer: "/Status/Health", Name: "chassis_status_health", Type: "health"},
+					{Pointer: "/Status/State", Name: "chassis_status_state", Type: "state"},
+					{Pointer: "/PowerState", Name: "chassis_power_state", Type: "number"},
+				},
+			},
+			{
+				Path: "/redfish/v1/Managers/{manager}",
+				PropertyRules: []*redfish.PropertyRule{
+					{Pointer: "/Status/Health", Name: "manager_status_health", Type: "health"},
+				},
+			},
+		},
+	}
+	if err := rule.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := map[string]string{
+		"/redfish/v1/Chassis/1":           `{"Status": {"Health": "OK"}, "PowerState": "On"}`,
+		"/redfish/v1/Systems/1":           `{"Status": {"Health": "OK"}}`,
+		"/redfish/v1/Systems/1/Storage/1": `{"Drives": [{"Status": {"Health": "OK"}}]}`,
+		"/redfish/v1":                     `{"Name": "Root"}`,
+	}
+	data := make(map[string]*gabs.Container)
+	for path, page := range pages {
+		parsed, err := gabs.ParseJSON([]byte(page))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[path] = parsed
+	}
+
+	report := ruleCoverage(redfish.NewCollected(data, rule))
+	if report.complete() {
+		t.Error("incomplete coverage was reported as complete")
+	}
+	if len(report.unmatchedPaths) != 1 || report.unmatchedPaths[0] != "/redfish/v1/Managers/{manager}" {
+		t.Error("unexpected unmatched paths:", report.unmatchedPaths)
+	}
+	if len(report.notFound) != 1 || report.notFound[0].Pointer != "/Status/State" {
+		t.Errorf("unexpected pointers not found: %+v", report.notFound)
+	}
+	if len(report.conversionErrors) != 1 || report.conversionErrors[0].Pointer != "/PowerState" {
+		t.Errorf("unexpected conversion errors: %+v", report.conversionErrors)
+	}
+	expected := []string{"/redfish/v1/Systems/1", "/redfish/v1/Systems/1/Storage/1"}
+	if len(report.uncoveredPages) != len(expected) {
+		t.Fatal("unexpected uncovered pages:", report.uncoveredPages)
+	}
+	for i := range expected {
+		if report.uncoveredPages[i] != expected[i] {
+			t.Error("unexpected uncovered pages:", report.uncoveredPages)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cybozu-go/setup-hw/gabs"
+	"github.com/cybozu-go/setup-hw/redfish"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+var diffConfig struct {
+	format       string
+	ignoreFields []string
+}
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff OLD_FILE NEW_FILE",
+	Short: "show differences of pages and keys between two Redfish data",
+	Long: `Show differences of pages and keys between two Redfish data.
+
+It takes two JSON files that were dumped by "collector show" command.
+Pages matched by a patterned Metrics.Path of the base rule are compared as one page.`,
+	Args: cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffConfig.format != "text" && diffConfig.format != "json" {
+			return fmt.Errorf("unknown format: %s", diffConfig.format)
+		}
+
+		var ignoreRegexp *regexp.Regexp
+		if len(diffConfig.ignoreFields) != 0 {
+			r, err := regexp.Compile(strings.Join(diffConfig.ignoreFields, "|"))
+			if err != nil {
+				return err
+			}
+			ignoreRegexp = r
+		}
+
+		well.Go(func(ctx context.Context) error {
+			oldCollected, err := collectOrLoad(ctx, args[0], rootConfig.baseRuleFile)
+			if err != nil {
+				return err
+			}
+			newCollected, err := collectOrLoad(ctx, args[1], rootConfig.baseRuleFile)
+			if err != nil {
+				return err
+			}
+
+			d := diffPages(pageKeys(oldCollected, ignoreRegexp), pageKeys(newCollected, ignoreRegexp))
+			if diffConfig.format == "json" {
+				out, err := json.MarshalIndent(d, "", "    ")
+				if err != nil {
+					return err
+				}
+				_, err = os.Stdout.Write(append(out, '\n'))
+				return err
+			}
+			return d.write(os.Stdout)
+		})
+
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+// dataDiff is the differences between two Redfish data.
+type dataDiff struct {
+	AddedPages   []string    `json:"addedPages"`
+	RemovedPages []string    `json:"removedPages"`
+	ChangedPages []*pageDiff `json:"changedPages"`
+}
+
+// pageDiff is the differences of keys in a page.
+type pageDiff struct {
+	Path        string   `json:"path"`
+	AddedKeys   []string `json:"addedKeys,omitempty"`
+	RemovedKeys []string `json:"removedKeys,omitempty"`
+	// TypeChangedKeys are the keys whose values changed their JSON types, like "/Key: string -> number".
+	TypeChangedKeys []string `json:"typeChangedKeys,omitempty"`
+}
+
+// pageKeys returns the keys and the types of their values for each page.
+// Pages matched by a metric rule of the base rule are normalized into its patterned path, and their keys are merged.
+func pageKeys(collected *redfish.Collected, ignoreRegexp *regexp.Regexp) map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for path, page := range collected.Data() {
+		normalized := path
+		if collected.Rule() != nil {
+			for _, mr := range collected.Rule().MetricRules {
+				if matched, _ := mr.MatchPath(path); matched {
+					normalized = mr.Path
+					break
+				}
+			}
+		}
+
+		keys, ok := result[normalized]
+		if !ok {
+			keys = make(map[string]string)
+			result[normalized] = keys
+		}
+		flattenKeys(page, "", ignoreRegexp, keys)
+	}
+	return result
+}
+
+// flattenKeys collects the pointers to the scalar values in current and their types.
+// Elements of arrays are represented by "*".
+func flattenKeys(current *gabs.Container, prefix string, ignoreRegexp *regexp.Regexp, keys map[string]string) {
+	if childrenMap, err := current.ChildrenMap(); err == nil {
+		for k, v := range childrenMap {
+			if ignoreRegexp != nil && ignoreRegexp.MatchString(k) {
+				continue
+			}
+			flattenKeys(v, prefix+"/"+k, ignoreRegexp, keys)
+		}
+		return
+	}
+
+	if children, err := current.Children(); err == nil {
+		if len(children) == 0 {
+			keys[prefix] = mergeTypes(keys[prefix], "array")
+		}
+		for _, child := range children {
+			flattenKeys(child, prefix+"/*", ignoreRegexp, keys)
+		}
+		return
+	}
+
+	keys[prefix] = mergeTypes(keys[prefix], jsonType(current.Data()))
+}
+
+// mergeTypes adds typ to types, which are the types of a key found in other pages or elements
+// joined by "|" in sorted order, e.g. "number|string".
+// "null" is dropped if the key has a non-null type.
+func mergeTypes(types, typ string) string {
+	if types == "" {
+		return typ
+	}
+	set := map[string]bool{typ: true}
+	for _, t := range strings.Split(types, "|") {
+		set[t] = true
+	}
+	if len(set) > 1 {
+		delete(set, "null")
+	}
+	merged := make([]string, 0, len(set))
+	for t := range set {
+		merged = append(merged, t)
+	}
+	sort.Strings(merged)
+	return strings.Join(merged, "|")
+}
+
+func jsonType(data interface{}) string {
+	switch data.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "unknown"
+}
+
+func diffPages(oldPages, newPages map[string]map[string]string) *dataDiff {
+	d := &dataDiff{
+		AddedPages:   []string{},
+		RemovedPages: []string{},
+		ChangedPages: []*pageDiff{},
+	}
+
+	for path := range newPages {
+		if _, ok := oldPages[path]; !ok {
+			d.AddedPages = append(d.AddedPages, path)
+		}
+	}
+	for path, oldKeys := range oldPages {
+		newKeys, ok := newPages[path]
+		if !ok {
+			d.RemovedPages = append(d.RemovedPages, path)
+			continue
+		}
+		if pd := diffKeys(path, oldKeys, newKeys); pd != nil {
+			d.ChangedPages = append(d.ChangedPages, pd)
+		}
+	}
+
+	sort.Strings(d.AddedPages)
+	sort.Strings(d.RemovedPages)
+	sort.Slice(d.ChangedPages, func(i, j int) bool {
+		return d.ChangedPages[i].Path < d.ChangedPages[j].Path
+	})
+	return d
+}
+
+func diffKeys(path string, oldKeys, newKeys map[string]string) *pageDiff {
+	pd := &pageDiff{Path: path}
+	for key := range newKeys {
+		if _, ok := oldKeys[key]; !ok {
+			pd.AddedKeys = append(pd.AddedKeys, key)
+		}
+	}
+	for key, oldType := range oldKeys {
+		newType, ok := newKeys[key]
+		if !ok {
+			pd.RemovedKeys = append(pd.RemovedKeys, key)
+			continue
+		}
+		if oldType != newType {
+			pd.TypeChangedKeys = append(pd.TypeChangedKeys, fmt.Sprintf("%s: %s -> %s", key, oldType, newType))
+		}
+	}
+
+	if len(pd.AddedKeys) == 0 && len(pd.RemovedKeys) == 0 && len(pd.TypeChangedKeys) == 0 {
+		return nil
+	}
+	sort.Strings(pd.AddedKeys)
+	sort.Strings(pd.RemovedKeys)
+	sort.Strings(pd.TypeChangedKeys)
+	return pd
+}
+
+func (d *dataDiff) write(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("Added pages: %d\n", len(d.AddedPages))
+	for _, p := range d.AddedPages {
+		printf("    + %s\n", p)
+	}
+	printf("Removed pages: %d\n", len(d.RemovedPages))
+	for _, p := range d.RemovedPages {
+		printf("    - %s\n", p)
+	}
+	printf("Changed pages: %d\n", len(d.ChangedPages))
+	for _, pd := range d.ChangedPages {
+		printf("    %s\n", pd.Path)
+		for _, k := range pd.AddedKeys {
+			printf("        + %s\n", k)
+		}
+		for _, k := range pd.RemovedKeys {
+			printf("        - %s\n", k)
+		}
+		for _, k := range pd.TypeChangedKeys {
+			printf("        ~ %s\n", k)
+		}
+	}
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringVar(&diffConfig.format, "format", "text", "output format: text or json")
+	diffCmd.Flags().StringSliceVar(&diffConfig.ignoreFields, "ignore-field", nil, "regular expression of key names to be ignored; multiple values are joined with |")
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cybozu-go/setup-hw/gabs"
+	"github.com/cybozu-go/setup-hw/redfish"
+)
+
+func TestDiffPages(t *testing.T) {
+	rule := &redfish.CollectRule{
+		TraverseRule: redfish.TraverseRule{Root: "/redfish/v1"},
+		MetricRules: []*redfish.MetricRule{
+			{Path: "/redfish/v1/Chassis/{chassis}"},
+		},
+	}
+	if err := rule.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	collected := func(pages map[string]string) *redfish.Collected {
+		data := make(map[string]*gabs.Container)
+		for path, page := range pages {
+			parsed, err := gabs.ParseJSON([]byte(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data[path] = parsed
+		}
+		return redfish.NewCollected(data, rule)
+	}
+
+	oldData := collected(map[string]string{
+		"/redfish/v1":           `{"Name": "Root"}`,
+		"/redfish/v1/Chassis/1": `{"Status": {"Health": "OK"}, "Power": 1, "Links": [{"@odata.id": "/a"}]}`,
+		"/redfish/v1/Old":       `{}`,
+	})
+	newData := collected(map[string]string{
+		"/redfish/v1":                    `{"Name": "Root"}`,
+		"/redfish/v1/Chassis/A":          `{"Status": {"HealthRollup": "OK"}, "Power": "1"}`,
+		"/redfish/v1/Chassis/B":          `{"Status": {"HealthRollup": null}, "Links": []}`,
+		"/redfish/v1/Chassis/B/Sensor/1": `{}`,
+	})
+
+	d := diffPages(pageKeys(oldData, nil), pageKeys(newData, nil))
+
+	expected := &dataDiff{
+		AddedPages:   []string{"/redfish/v1/Chassis/B/Sensor/1"},
+		RemovedPages: []string{"/redfish/v1/Old"},
+		ChangedPages: []*pageDiff{
+			{
+				Path:            "/redfish/v1/Chassis/{chassis}",
+				AddedKeys:       []string{"/Links", "/Status/HealthRollup"},
+				RemovedKeys:     []string{"/Links/*/@odata.id", "/Status/Health"},
+				TypeChangedKeys: []string{"/Power: number -> string"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("unexpected diff;\nexpected: %+v\nactual:   %+v", expected.ChangedPages[0], d.ChangedPages)
+	}
+}
+
+func TestFlattenKeysMergeTypes(t *testing.T) {
+	t.Parallel()
+
+	// The types of a key in array elements are merged regardless of their order.
+	for _, data := range []string{
+		`{"Values": [1, "a", null, true]}`,
+		`{"Values": [null, true, "a", 1]}`,
+	} {
+		parsed, err := gabs.ParseJSON([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys := make(map[string]string)
+		flattenKeys(parsed, "", nil, keys)
+		if keys["/Values/*"] != "boolean|number|string" {
+			t.Errorf("%s: unexpected type: %s", data, keys["/Values/*"])
+		}
+	}
+
+	parsed, err := gabs.ParseJSON([]byte(`{"Values": [null, null]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]string)
+	flattenKeys(parsed, "", nil, keys)
+	if keys["/Values/*"] != "null" {
+		t.Error("unexpected type of null values:", keys["/Values/*"])
+	}
+}
@@ -16,7 +16,10 @@ import (
 )
 
 var generateRuleConfig struct {
-	keys []string
+	keys     []string
+	auto     bool
+	includes []string
+	excludes []string
 }
 
 type keyType struct {
@@ -24,6 +27,28 @@ type keyType struct {
 	typ string
 }
 
+// autoTyper finds properties not specified by --key, and infers their types from their values.
+type autoTyper struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// typeOf returns the type of the property if it should be exported.
+// Keys including "@" are OData annotations, and are never exported.
+// typeOf of a nil autoTyper returns false.
+func (a *autoTyper) typeOf(key string, value interface{}) (string, bool) {
+	if a == nil || strings.Contains(key, "@") {
+		return "", false
+	}
+	if a.include != nil && !a.include.MatchString(key) {
+		return "", false
+	}
+	if a.exclude != nil && a.exclude.MatchString(key) {
+		return "", false
+	}
+	return redfish.InferType(value)
+}
+
 // generateRuleCmd represents the generateRule command
 var generateRuleCmd = &cobra.Command{
 	Use:   "generate-rule FILE...",
@@ -46,6 +71,25 @@ It takes one or more JSON file names that were dumped by "collector show" comman
 			}
 		}
 
+		var auto *autoTyper
+		if generateRuleConfig.auto {
+			auto = new(autoTyper)
+			if len(generateRuleConfig.includes) != 0 {
+				r, err := regexp.Compile(strings.Join(generateRuleConfig.includes, "|"))
+				if err != nil {
+					return err
+				}
+				auto.include = r
+			}
+			if len(generateRuleConfig.excludes) != 0 {
+				r, err := regexp.Compile(strings.Join(generateRuleConfig.excludes, "|"))
+				if err != nil {
+					return err
+				}
+				auto.exclude = r
+			}
+		}
+
 		well.Go(func(ctx context.Context) error {
 			rules := make([]*redfish.CollectRule, len(args))
 			for i, fname := range args {
@@ -54,7 +98,7 @@ It takes one or more JSON file names that were dumped by "collector show" comman
 					return err
 				}
 
-				metricRules := generateRule(collected.Data(), keyTypes, collected.Rule())
+				metricRules := generateRule(collected.Data(), keyTypes, auto, collected.Rule())
 				collectRule := &redfish.CollectRule{
 					TraverseRule: collected.Rule().TraverseRule,
 					MetricRules:  metricRules,
@@ -80,7 +124,7 @@ It takes one or more JSON file names that were dumped by "collector show" comman
 	},
 }
 
-func generateRule(data map[string]*gabs.Container, keyTypes []*keyType, rule *redfish.CollectRule) []*redfish.MetricRule {
+func generateRule(data map[string]*gabs.Container, keyTypes []*keyType, auto *autoTyper, rule *redfish.CollectRule) []*redfish.MetricRule {
 	var rules []*redfish.MetricRule
 
 	matchedRules := make(map[string]bool)
@@ -100,7 +144,7 @@ OUTER:
 			}
 		}
 		relPath := path[len(rule.TraverseRule.Root):]
-		propertyRules := generateRuleAux(parsedJSON, keyTypes, relPath, "", []*redfish.PropertyRule{})
+		propertyRules := generateRuleAux(parsedJSON, keyTypes, auto, relPath, "", []*redfish.PropertyRule{})
 
 		if len(propertyRules) > 0 {
 			sort.Slice(propertyRules, func(i, j int) bool { return propertyRules[i].Pointer < propertyRules[j].Pointer })
@@ -115,7 +159,7 @@ OUTER:
 	return rules
 }
 
-func generateRuleAux(data *gabs.Container, keyTypes []*keyType, path, pointer string, rules []*redfish.PropertyRule) []*redfish.PropertyRule {
+func generateRuleAux(data *gabs.Container, keyTypes []*keyType, auto *autoTyper, path, pointer string, rules []*redfish.PropertyRule) []*redfish.PropertyRule {
 	if childrenMap, err := data.ChildrenMap(); err == nil {
 		for k, v := range childrenMap {
 			newPointer := pointer + "/" + k
@@ -126,8 +170,14 @@ func generateRuleAux(data *gabs.Container, keyTypes []*keyType, path, pointer st
 					Name:    generateMetricName(path, newPointer),
 					Type:    kt.typ,
 				})
+			} else if typ, ok := auto.typeOf(k, v.Data()); ok {
+				rules = append(rules, &redfish.PropertyRule{
+					Pointer: newPointer,
+					Name:    generateMetricName(path, newPointer),
+					Type:    typ,
+				})
 			} else {
-				rules = generateRuleAux(v, keyTypes, path, newPointer, rules)
+				rules = generateRuleAux(v, keyTypes, auto, path, newPointer, rules)
 			}
 		}
 		return rules
@@ -143,7 +193,7 @@ func generateRuleAux(data *gabs.Container, keyTypes []*keyType, path, pointer st
 		} else if strings.HasSuffix(parent, "s") {
 			parent = regexp.MustCompile("s$").ReplaceAllString(parent, "")
 		}
-		return generateRuleAux(v, keyTypes, path, pointer+"/{"+strings.ToLower(parent)+"}", rules)
+		return generateRuleAux(v, keyTypes, auto, path, pointer+"/{"+strings.ToLower(parent)+"}", rules)
 	}
 
 	return rules
@@ -241,4 +291,7 @@ func init() {
 	rootCmd.AddCommand(generateRuleCmd)
 
 	generateRuleCmd.Flags().StringSliceVar(&generateRuleConfig.keys, "key", nil, "Redfish data key to find")
+	generateRuleCmd.Flags().BoolVar(&generateRuleConfig.auto, "auto", false, "find keys not given by --key, and infer their types from their values")
+	generateRuleCmd.Flags().StringSliceVar(&generateRuleConfig.includes, "include", nil, "key pattern to be found in --auto mode")
+	generateRuleCmd.Flags().StringSliceVar(&generateRuleConfig.excludes, "exclude", nil, "key pattern not to be found in --auto mode")
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"regexp"
 	"testing"
 
 	"github.com/cybozu-go/setup-hw/gabs"
@@ -108,7 +109,7 @@ func TestGenerateRule(t *testing.T) {
 			key: "LineInputVoltage",
 			typ: "number",
 		},
-	}, &redfish.CollectRule{
+	}, nil, &redfish.CollectRule{
 		TraverseRule: redfish.TraverseRule{
 			Root: defaultRootPath,
 		},
@@ -245,3 +246,90 @@ func TestMergeCollectRules(t *testing.T) {
 		t.Error("mergeCollectRules() returned unexpected result:", cmp.Diff(expected, merged, opts))
 	}
 }
+
+func TestGenerateRuleAuto(t *testing.T) {
+	t.Parallel()
+
+	systemPath := "/redfish/v1/Systems/System.Embedded.1"
+	systemPatternedPath := "/redfish/v1/Systems/{system}"
+	systemJSON := `
+{
+    "@odata.id": "/redfish/v1/Systems/System.Embedded.1",
+    "@odata.count": 1,
+    "Name": "System",
+    "PowerState": "On",
+    "MemorySummary": {
+        "TotalSystemMemoryGiB": 128,
+        "Status": {
+            "Health": "OK",
+            "State": "Enabled"
+        }
+    },
+    "TrustedModules": [
+        {
+            "InterfaceType": "TPM2_0",
+            "Status": {
+                "State": "Disabled"
+            }
+        }
+    ],
+    "LastResetTime": null,
+    "Enabled": true
+}
+`
+	systemParsedJSON, err := gabs.ParseJSON([]byte(systemJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := map[string]*gabs.Container{
+		systemPath: systemParsedJSON,
+	}
+
+	exclude := regexp.MustCompile("^Enabled$")
+
+	expected := []*redfish.MetricRule{
+		{
+			Path: systemPatternedPath,
+			PropertyRules: []*redfish.PropertyRule{
+				{
+					Pointer: "/MemorySummary/Status/Health",
+					Name:    "systems_memorysummary_status_health",
+					Type:    "health",
+				},
+				{
+					Pointer: "/MemorySummary/Status/State",
+					Name:    "systems_memorysummary_status_state",
+					Type:    "state",
+				},
+				{
+					Pointer: "/MemorySummary/TotalSystemMemoryGiB",
+					Name:    "systems_memorysummary_totalsystemmemorygib",
+					Type:    "number",
+				},
+				{
+					Pointer: "/TrustedModules/{trustedmodule}/Status/State",
+					Name:    "systems_trustedmodules_status_state",
+					Type:    "state",
+				},
+			},
+		},
+	}
+
+	// collector generate-rule --auto --exclude=^Enabled$
+	result := generateRule(input, nil, &autoTyper{exclude: exclude}, &redfish.CollectRule{
+		TraverseRule: redfish.TraverseRule{
+			Root: defaultRootPath,
+		},
+		MetricRules: []*redfish.MetricRule{
+			{
+				Path: systemPatternedPath,
+			},
+		},
+	})
+
+	opts := cmpopts.IgnoreUnexported(redfish.TraverseRule{}, redfish.PropertyRule{})
+	if !cmp.Equal(result, expected, opts) {
+		t.Error("generateRule() returned unexpected result:", cmp.Diff(expected, result, opts))
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"os"
@@ -12,6 +13,43 @@ import (
 )
 
 func collectOrLoad(ctx context.Context, inputFile string, baseRule string) (*redfish.Collected, error) {
+	rule, err := loadBaseRule(baseRule)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(inputFile) == 0 {
+		client, err := newRedfishClient(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		collected := client.Traverse(ctx, rule)
+		return &collected, nil
+	}
+
+	input, err := loadInput(inputFile)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]*gabs.Container)
+	for k, v := range input {
+		if !rule.TraverseRule.NeedTraverse(k) {
+			continue
+		}
+		c, err := gabs.Consume(v)
+		if err != nil {
+			return nil, err
+		}
+		data[k] = c
+	}
+	return redfish.NewCollected(data, rule), nil
+}
+
+// loadBaseRule loads and compiles the base rule.
+// If baseRule is empty, it returns a rule which traverses all pages.
+func loadBaseRule(baseRule string) (*redfish.CollectRule, error) {
 	var rule *redfish.CollectRule
 	if len(baseRule) != 0 {
 		data, err := os.ReadFile(baseRule)
@@ -36,48 +74,45 @@ func collectOrLoad(ctx context.Context, inputFile string, baseRule string) (*red
 	if err := rule.Compile(); err != nil {
 		return nil, err
 	}
+	return rule, nil
+}
 
-	if len(inputFile) == 0 {
-		ac, uc, err := config.LoadConfig()
-		if err != nil {
-			return nil, err
-		}
-
-		cc := &redfish.ClientConfig{
-			AddressConfig: ac,
-			UserConfig:    uc,
-			NoEscape:      true,
-		}
-		client, err := redfish.NewRedfishClient(cc)
-		if err != nil {
-			return nil, err
-		}
+// newRedfishClient creates a client for the local BMC.
+func newRedfishClient(recorder redfish.Recorder) (redfish.Client, error) {
+	ac, uc, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
 
-		collected := client.Traverse(ctx, rule)
-		return &collected, nil
+	cc := &redfish.ClientConfig{
+		AddressConfig: ac,
+		UserConfig:    uc,
+		NoEscape:      true,
+		Parallelism:   rootConfig.parallelism,
+		Recorder:      recorder,
 	}
+	return redfish.NewRedfishClient(cc)
+}
 
+// loadInput reads Redfish data from a JSON file dumped by "collector show",
+// or from an archive created by "collector capture".
+func loadInput(inputFile string) (map[string]interface{}, error) {
 	f, err := os.Open(inputFile)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(len(gzipMagic))
+	if err == nil && string(magic) == gzipMagic {
+		return readCaptureArchive(r)
+	}
 
 	var input map[string]interface{}
-	err = json.NewDecoder(f).Decode(&input)
+	err = json.NewDecoder(r).Decode(&input)
 	if err != nil {
 		return nil, err
 	}
-
-	data := make(map[string]*gabs.Container)
-	for k, v := range input {
-		if !rule.TraverseRule.NeedTraverse(k) {
-			continue
-		}
-		c, err := gabs.Consume(v)
-		if err != nil {
-			return nil, err
-		}
-		data[k] = c
-	}
-	return redfish.NewCollected(data, rule), nil
+	return input, nil
 }
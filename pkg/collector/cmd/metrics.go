@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/setup-hw/redfish"
+	"github.com/cybozu-go/well"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/cobra"
+)
+
+var metricsConfig struct {
+	inputFile string
+	ruleFile  string
+}
+
+// metricsCmd represents the metrics command
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "show metrics converted from Redfish data by a collection rule",
+	Long: `Show metrics converted from Redfish data by a collection rule in Prometheus text format.
+
+It also warns of property rules which produced no metric.`,
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ruleFile := metricsConfig.ruleFile
+		if ruleFile == "" {
+			ruleFile = rootConfig.baseRuleFile
+		}
+		if ruleFile == "" {
+			return errors.New("--rule is mandatory")
+		}
+
+		well.Go(func(ctx context.Context) error {
+			collected, err := collectOrLoad(ctx, metricsConfig.inputFile, ruleFile)
+			if err != nil {
+				return err
+			}
+
+			metrics, matches := collected.Metrics()
+			warnUnmatched(matches)
+			return writeMetrics(os.Stdout, metrics)
+		})
+
+		well.Stop()
+		return well.Wait()
+	},
+}
+
+// warnUnmatched logs the property rules which produced no metric.
+func warnUnmatched(matches []redfish.PropertyMatch) {
+	for _, m := range matches {
+		if m.Metrics != 0 {
+			continue
+		}
+		fields := map[string]interface{}{
+			"path":    m.Path,
+			"pointer": m.Pointer,
+			"name":    m.Name,
+		}
+		if m.Pages == 0 {
+			log.Warn("path matched no page", fields)
+		} else {
+			fields["pages"] = m.Pages
+			log.Warn("pointer matched nothing", fields)
+		}
+	}
+}
+
+type metricsCollector []prometheus.Metric
+
+// Describe sends no description, so that metricsCollector is regarded as unchecked.
+func (c metricsCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c {
+		ch <- m
+	}
+}
+
+// writeMetrics writes metrics in the Prometheus text exposition format.
+func writeMetrics(w io.Writer, metrics []prometheus.Metric) error {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(metricsCollector(metrics)); err != nil {
+		return err
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(metricsCmd)
+	metricsCmd.Flags().StringVar(&metricsConfig.inputFile, "input-file", "", "pre-collected Redfish data")
+	metricsCmd.Flags().StringVar(&metricsConfig.ruleFile, "rule", "", "collection rule file (default is the file given by --base-rule)")
+}
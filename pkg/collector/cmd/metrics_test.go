@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	desc := prometheus.NewDesc("hw_chassis_status_health", "", []string{"chassis"}, nil)
+	metrics := []prometheus.Metric{
+		prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, "2"),
+		prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 0, "1"),
+	}
+
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP hw_chassis_status_health 
+# TYPE hw_chassis_status_health gauge
+hw_chassis_status_health{chassis="1"} 0
+hw_chassis_status_health{chassis="2"} 1
+`
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("unexpected output; expected: %s, actual: %s", expected, buf.String())
+	}
+}
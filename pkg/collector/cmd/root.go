@@ -9,10 +9,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-const defaultRootPath = "/redfish/v1"
+const (
+	defaultRootPath    = "/redfish/v1"
+	defaultParallelism = 4
+)
 
 var rootConfig struct {
 	baseRuleFile string
+	parallelism  int
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -43,4 +47,5 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&rootConfig.baseRuleFile, "base-rule", "", "based rule file")
+	rootCmd.PersistentFlags().IntVar(&rootConfig.parallelism, "parallelism", defaultParallelism, "maximum number of concurrent requests to BMC")
 }
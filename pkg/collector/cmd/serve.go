@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/setup-hw/fakebmc"
+	"github.com/cybozu-go/well"
+	"github.com/spf13/cobra"
+)
+
+const defaultServeAddress = ":8443"
+
+var serveConfig struct {
+	inputFiles []string
+	listen     []string
+	user       string
+	password   string
+	noSession  bool
+}
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "serve collected Redfish data as a fake BMC",
+	Long: `Serve collected Redfish data as a fake BMC over HTTPS.
+
+It takes JSON files that were dumped by "collector show" command, or archives
+created by "collector capture" command.
+Each file is served at the address given by --listen in the same order.`,
+	Args: cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(serveConfig.inputFiles) == 0 {
+			return errors.New("--input-file is mandatory")
+		}
+		listen := serveConfig.listen
+		if len(listen) == 0 && len(serveConfig.inputFiles) == 1 {
+			listen = []string{defaultServeAddress}
+		}
+		if len(listen) != len(serveConfig.inputFiles) {
+			return errors.New("--listen must be given for each --input-file")
+		}
+
+		bmcConfig := fakebmc.Config{
+			User:      serveConfig.user,
+			Password:  serveConfig.password,
+			NoSession: serveConfig.noSession,
+			NoAuth:    serveConfig.password == "",
+		}
+
+		for i, inputFile := range serveConfig.inputFiles {
+			pages, err := loadPages(inputFile)
+			if err != nil {
+				return err
+			}
+			addr, err := serveFakeBMC(nil, listen[i], fakebmc.New(pages, bmcConfig))
+			if err != nil {
+				return err
+			}
+			log.Info("serving fake BMC", map[string]interface{}{
+				"input":  inputFile,
+				"listen": addr.String(),
+				"pages":  len(pages),
+			})
+		}
+
+		well.Stop()
+		err := well.Wait()
+		if err != nil && !well.IsSignaled(err) {
+			return err
+		}
+		return nil
+	},
+}
+
+// loadPages reads Redfish data for a fake BMC.
+func loadPages(inputFile string) (map[string]json.RawMessage, error) {
+	input, err := loadInput(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	return fakebmc.ParseDump(data)
+}
+
+// serveFakeBMC starts serving bmc over HTTPS with a self-signed certificate, and returns the listening address.
+// The server runs in env, or in the global environment if env is nil.
+func serveFakeBMC(env *well.Environment, addr string, bmc http.Handler) (net.Addr, error) {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, err := net.SplitHostPort(addr); err == nil && host != "" {
+		hosts = append(hosts, host)
+	}
+	cert, err := fakebmc.SelfSignedCertificate(hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	serv := &well.HTTPServer{
+		Server: &http.Server{
+			Handler:   bmc,
+			TLSConfig: tlsConfig,
+		},
+		Env: env,
+	}
+	if err := serv.Serve(tls.NewListener(ln, tlsConfig)); err != nil {
+		return nil, err
+	}
+	return ln.Addr(), nil
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringSliceVar(&serveConfig.inputFiles, "input-file", nil, "pre-collected Redfish data to be served")
+	serveCmd.Flags().StringSliceVar(&serveConfig.listen, "listen", nil, "listening address for each input file (default \""+defaultServeAddress+"\" for a single input file)")
+	serveCmd.Flags().StringVar(&serveConfig.user, "user", "support", "BMC user accepted by the fake BMC")
+	serveCmd.Flags().StringVar(&serveConfig.password, "password", "", "password of the BMC user; any credentials are accepted if empty")
+	serveCmd.Flags().BoolVar(&serveConfig.noSession, "no-session", false, "disable Redfish sessions to force basic authentication")
+}
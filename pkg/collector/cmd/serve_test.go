@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/cybozu-go/setup-hw/fakebmc"
+	"github.com/cybozu-go/well"
+)
+
+func TestServeFakeBMC(t *testing.T) {
+	t.Parallel()
+
+	pages, err := loadPages("../../../testdata/fakebmc_dump.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	env := well.NewEnvironment(context.Background())
+	defer func() {
+		env.Cancel(nil)
+		if err := env.Wait(); err != nil {
+			t.Error(err)
+		}
+	}()
+	bmc := fakebmc.New(pages, fakebmc.Config{User: "support", Password: "secret"})
+	addr, err := serveFakeBMC(env, "127.0.0.1:0", bmc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(user, password string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, "https://"+addr.String()+"/redfish/v1", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(user, password)
+		return client.Do(req)
+	}
+
+	resp, err := get("support", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status:", resp.StatusCode)
+	}
+	var root struct {
+		RedfishVersion string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&root); err != nil {
+		t.Fatal(err)
+	}
+	if root.RedfishVersion != "1.6.0" {
+		t.Error("unexpected page was served:", root)
+	}
+
+	resp, err = get("support", "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Error("wrong password was not rejected:", resp.StatusCode)
+	}
+}
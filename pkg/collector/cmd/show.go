@@ -265,5 +265,5 @@ func init() {
 	showCmd.Flags().StringSliceVar(&showConfig.requiredFields, "required-field", nil, "required fields to show a page")
 	showCmd.Flags().BoolVar(&showConfig.omitEmpty, "omit-empty", false, "omit empty arrays and objects")
 	showCmd.Flags().BoolVar(&showConfig.truncateArrays, "truncate-arrays", false, "show first array element only")
-	showCmd.Flags().StringSliceVar(&showConfig.ignoreFields, "ignore-field", nil, "ignore fields")
+	showCmd.Flags().StringSliceVar(&showConfig.ignoreFields, "ignore-field", nil, "regular expression of key names to be ignored; multiple values are joined with |")
 }
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/setup-hw/idrac"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// complianceCollector exports whether the settings of BIOS and BMC are the desired ones.
+// The settings are checked periodically in the same way as "setup-hw verify".
+type complianceCollector struct {
+	check         func(ctx context.Context) ([]*idrac.Result, error)
+	interval      time.Duration
+	compliant     *prometheus.Desc
+	success       *prometheus.Desc
+	lastSuccessAt *prometheus.Desc
+
+	mu          sync.Mutex
+	checked     bool
+	succeeded   bool
+	lastSuccess time.Time
+	results     []*idrac.Result
+}
+
+// newComplianceCollector returns a new complianceCollector which checks the settings with check.
+func newComplianceCollector(check func(ctx context.Context) ([]*idrac.Result, error), interval time.Duration) *complianceCollector {
+	return &complianceCollector{
+		check:    check,
+		interval: interval,
+		compliant: prometheus.NewDesc(prometheus.BuildFQName("hw", "config", "compliant"),
+			"1 if the setting is the desired one, 0 otherwise.", []string{"key"}, nil),
+		success: prometheus.NewDesc(prometheus.BuildFQName("hw", "config", "check_success"),
+			"1 if the last check of the settings succeeded, 0 otherwise.", nil, nil),
+		lastSuccessAt: prometheus.NewDesc(prometheus.BuildFQName("hw", "config", "last_successful_check_timestamp_seconds"),
+			"Unix time of the last successful check of the settings.", nil, nil),
+	}
+}
+
+// run checks the settings every interval until ctx is canceled.
+// If the check fails, the previous results are kept, and the failure is exported
+// so that the results can be regarded as stale.
+func (c *complianceCollector) run(ctx context.Context) error {
+	for {
+		results, err := c.check(ctx)
+		if err != nil {
+			log.Error("failed to check compliance of hardware settings", map[string]interface{}{
+				log.FnError: err,
+			})
+		}
+
+		c.mu.Lock()
+		c.checked = true
+		c.succeeded = err == nil
+		if err == nil {
+			c.results = results
+			c.lastSuccess = time.Now()
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-time.After(c.interval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *complianceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.compliant
+	ch <- c.success
+	ch <- c.lastSuccessAt
+}
+
+// Collect implements prometheus.Collector.
+func (c *complianceCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checked {
+		return
+	}
+	success := 0.0
+	if c.succeeded {
+		success = 1.0
+	}
+	ch <- prometheus.MustNewConstMetric(c.success, prometheus.GaugeValue, success)
+	if !c.lastSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(c.lastSuccessAt, prometheus.GaugeValue, float64(c.lastSuccess.Unix()))
+	}
+
+	for _, r := range c.results {
+		value := 0.0
+		if r.Compliant {
+			value = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(c.compliant, prometheus.GaugeValue, value, r.Key)
+	}
+}
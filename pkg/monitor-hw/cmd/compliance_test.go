@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/setup-hw/idrac"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestComplianceCollector(t *testing.T) {
+	t.Parallel()
+
+	results := []*idrac.Result{
+		{Key: "BIOS.ProcSettings.LogicalProc", Current: "Enabled", Desired: "Disabled"},
+		{Key: "iDRAC.SNMP.AgentEnable", Current: "Enabled", Desired: "Enabled", Compliant: true},
+	}
+	var err error
+	check := func(ctx context.Context) ([]*idrac.Result, error) {
+		return results, err
+	}
+	c := newComplianceCollector(check, time.Hour)
+
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Error("no metrics should be exported before the check:", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP hw_config_check_success 1 if the last check of the settings succeeded, 0 otherwise.
+# TYPE hw_config_check_success gauge
+hw_config_check_success 1
+# HELP hw_config_compliant 1 if the setting is the desired one, 0 otherwise.
+# TYPE hw_config_compliant gauge
+hw_config_compliant{key="BIOS.ProcSettings.LogicalProc"} 0
+hw_config_compliant{key="iDRAC.SNMP.AgentEnable"} 1
+`
+	names := []string{"hw_config_check_success", "hw_config_compliant"}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), names...); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(c, "hw_config_last_successful_check_timestamp_seconds"); n != 1 {
+		t.Error("the time of the last successful check should be exported:", n)
+	}
+	lastSuccess := c.lastSuccess
+
+	// The previous results are kept if the check fails, but the failure is exported.
+	err = errors.New("racadm failed")
+	if err := c.run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expected = strings.Replace(expected, "\nhw_config_check_success 1\n", "\nhw_config_check_success 0\n", 1)
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), names...); err != nil {
+		t.Error(err)
+	}
+	if c.lastSuccess != lastSuccess {
+		t.Error("the time of the last successful check should not be changed by a failure")
+	}
+}
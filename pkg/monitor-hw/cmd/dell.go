@@ -6,10 +6,14 @@ import (
 	"time"
 
 	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/setup-hw/config"
+	"github.com/cybozu-go/setup-hw/idrac"
+	"github.com/cybozu-go/setup-hw/lib"
 	"github.com/cybozu-go/well"
 )
 
-func monitorDell(ctx context.Context) error {
+// monitorDell resets iDRAC periodically.  It also checks compliance of settings if compliance is not nil.
+func monitorDell(ctx context.Context, compliance *complianceCollector) error {
 	if err := initDell(ctx); err != nil {
 		return err
 	}
@@ -18,6 +22,9 @@ func monitorDell(ctx context.Context) error {
 	}
 
 	env := well.NewEnvironment(ctx)
+	if compliance != nil {
+		env.Go(compliance.run)
+	}
 	env.Go(func(ctx context.Context) error {
 		for {
 			select {
@@ -57,3 +64,32 @@ func initDell(ctx context.Context) error {
 func resetDell(ctx context.Context) error {
 	return well.CommandContext(ctx, "/opt/dell/srvadmin/bin/idracadm7", "racreset", "soft").Run()
 }
+
+// dellComplianceCollector returns a complianceCollector which checks the settings applied by setup-hw with idracadm7.
+func dellComplianceCollector(ac *config.AddressConfig, uc *config.UserConfig) (*complianceCollector, error) {
+	profile, err := config.LoadProfile(opts.profile)
+	if err != nil {
+		return nil, err
+	}
+	model, err := lib.DetectModel()
+	if err != nil {
+		return nil, err
+	}
+	hname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	desired := &idrac.Desired{
+		Profile:       profile,
+		AddressConfig: ac,
+		UserConfig:    uc,
+		Model:         model,
+		Hostname:      hname,
+	}
+	r := idrac.NewRacadm()
+	check := func(ctx context.Context) ([]*idrac.Result, error) {
+		return desired.Verify(ctx, r)
+	}
+	return newComplianceCollector(check, time.Duration(opts.complianceInterval)*time.Minute), nil
+}
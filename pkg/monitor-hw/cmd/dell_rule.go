@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/setup-hw/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	dellRulePrefix = "dell_redfish_"
+	dellRuleSuffix = redfish.RuleFileExt
+)
+
+// dellRuleSelector selects the rule for the Redfish version of iDRAC.
+// If no rule is available for the version, the rule of the nearest lower version is used.
+type dellRuleSelector struct {
+	client redfish.Client
+	// pinned is the Redfish version whose rule is always used if not empty.
+	pinned   string
+	ruleDesc *prometheus.Desc
+
+	mu       sync.Mutex
+	selected string
+	version  string
+}
+
+// newDellRuleSelector returns a new dellRuleSelector.
+func newDellRuleSelector(client redfish.Client, pinned string) *dellRuleSelector {
+	return &dellRuleSelector{
+		client: client,
+		pinned: pinned,
+		ruleDesc: prometheus.NewDesc(prometheus.BuildFQName("hw", "", "rule_info"),
+			"The collection rule in use and the Redfish version of the BMC.", []string{"rule", "version"}, nil),
+	}
+}
+
+// dellRuleFile returns the file name of the rule for the Redfish version.
+func dellRuleFile(version string) string {
+	return dellRulePrefix + version + dellRuleSuffix
+}
+
+// getRule implements redfish.RuleGetter.
+func (s *dellRuleSelector) getRule(ctx context.Context) (*redfish.CollectRule, error) {
+	var version string
+	if s.pinned != "" {
+		version = s.pinned
+	} else {
+		v, err := s.client.GetVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+		version = v
+	}
+
+	ruleFile, err := selectDellRule(version, rules.names())
+	if err != nil {
+		return nil, err
+	}
+	rule, ok := rules.get(ruleFile)
+	if !ok {
+		return nil, errors.New("unknown rule file: " + ruleFile)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ruleFile != s.selected || version != s.version {
+		fields := map[string]interface{}{
+			"rule":    ruleFile,
+			"version": version,
+		}
+		if ruleFile != dellRuleFile(version) {
+			log.Warn("no rule for the Redfish version; using the rule of the nearest lower version", fields)
+		} else {
+			log.Info("selected rule", fields)
+		}
+		s.selected = ruleFile
+		s.version = version
+	}
+	return rule, nil
+}
+
+// selectDellRule returns the rule file for the Redfish version from names.
+// It returns the rule of the version itself if exists, or that of the nearest lower version.
+func selectDellRule(version string, names []string) (string, error) {
+	target, err := parseVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("invalid Redfish version %q: %w", version, err)
+	}
+
+	var selected string
+	var selectedVersion []int
+	for _, name := range names {
+		if !strings.HasPrefix(name, dellRulePrefix) || !strings.HasSuffix(name, dellRuleSuffix) {
+			continue
+		}
+		v, err := parseVersion(strings.TrimSuffix(strings.TrimPrefix(name, dellRulePrefix), dellRuleSuffix))
+		if err != nil {
+			continue
+		}
+		if compareVersions(v, target) > 0 {
+			continue
+		}
+		if selected == "" || compareVersions(v, selectedVersion) > 0 {
+			selected = name
+			selectedVersion = v
+		}
+	}
+
+	if selected == "" {
+		return "", fmt.Errorf("no rule file for Redfish version %s or lower", version)
+	}
+	return selected, nil
+}
+
+// parseVersion parses a version in the form of `major.minor.patch`.
+// Missing minor and patch numbers are regarded as 0.
+func parseVersion(version string) ([]int, error) {
+	elems := strings.Split(version, ".")
+	if len(elems) > 3 {
+		return nil, errors.New("too many elements")
+	}
+
+	v := make([]int, 3)
+	for i, elem := range elems {
+		n, err := strconv.Atoi(elem)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, errors.New("negative number")
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+func compareVersions(a, b []int) int {
+	for i := range a {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Describe implements prometheus.Collector.
+func (s *dellRuleSelector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.ruleDesc
+}
+
+// Collect implements prometheus.Collector.
+func (s *dellRuleSelector) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.selected == "" {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(s.ruleDesc, prometheus.GaugeValue, 1, s.selected, s.version)
+}
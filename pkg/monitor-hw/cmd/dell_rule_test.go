@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cybozu-go/setup-hw/redfish"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSelectDellRule(t *testing.T) {
+	t.Parallel()
+
+	names := []string{
+		"dell_redfish_1.0.2.yml",
+		"dell_redfish_1.2.0.yml",
+		"dell_redfish_1.10.0.yml",
+		"dell_redfish_broken.yml",
+		"qemu.yml",
+	}
+
+	testcases := []struct {
+		version  string
+		expected string
+	}{
+		{"1.2.0", "dell_redfish_1.2.0.yml"},
+		{"1.2.1", "dell_redfish_1.2.0.yml"},
+		{"1.9.0", "dell_redfish_1.2.0.yml"},
+		{"1.10.0", "dell_redfish_1.10.0.yml"},
+		{"2.0", "dell_redfish_1.10.0.yml"},
+		{"1.0.2", "dell_redfish_1.0.2.yml"},
+		{"1.0.1", ""},
+		{"1.x", ""},
+	}
+
+	for _, tc := range testcases {
+		actual, err := selectDellRule(tc.version, names)
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("%s: rule was selected: %s", tc.version, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.version, err)
+			continue
+		}
+		if actual != tc.expected {
+			t.Errorf("%s: expected %s, actual %s", tc.version, tc.expected, actual)
+		}
+	}
+}
+
+type versionClient struct {
+	redfish.Client
+	version string
+}
+
+func (c versionClient) GetVersion(context.Context) (string, error) {
+	return c.version, nil
+}
+
+func TestDellRuleSelector(t *testing.T) {
+	t.Parallel()
+
+	s := newDellRuleSelector(versionClient{version: "1.6.1"}, "")
+	rule, err := s.getRule(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule != redfish.Rules["dell_redfish_1.6.0.yml"] {
+		t.Error("rule of the nearest lower version was not selected")
+	}
+	if n := testutil.CollectAndCount(s); n != 1 {
+		t.Errorf("unexpected number of metrics: %d", n)
+	}
+
+	s = newDellRuleSelector(versionClient{version: "1.6.1"}, "1.4.0")
+	rule, err = s.getRule(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule != redfish.Rules["dell_redfish_1.4.0.yml"] {
+		t.Error("pinned rule was not selected")
+	}
+}
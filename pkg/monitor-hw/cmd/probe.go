@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/setup-hw/config"
+	"github.com/cybozu-go/setup-hw/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultProbeModule = "default"
+
+// probeConfig represents the configuration file of probe modules.
+type probeConfig struct {
+	Modules map[string]*probeModule `json:"modules"`
+}
+
+// probeModule specifies how to probe a remote BMC.
+type probeModule struct {
+	// User and Password are the credentials of the BMC user.
+	User     string `json:"user"`
+	Password string `json:"password"`
+
+	// Port is the port number of Redfish API.  If empty, the default HTTPS port is used.
+	Port string `json:"port,omitempty"`
+
+	// Rule is the name of the collection rule.
+	// If empty, the rule is selected by the Redfish version of the BMC as the local monitor does for Dell servers.
+	Rule string `json:"rule,omitempty"`
+
+	// Timeout is the timeout of a probe in seconds.  If zero, a probe does not time out.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+func loadProbeConfig(filename string) (*probeConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := new(probeConfig)
+	if err := yaml.Unmarshal(data, pc); err != nil {
+		return nil, err
+	}
+	if err := pc.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return pc, nil
+}
+
+func (pc *probeConfig) validate() error {
+	if len(pc.Modules) == 0 {
+		return errors.New("no probe module is defined")
+	}
+
+	for name, m := range pc.Modules {
+		if m == nil {
+			return errors.New("empty probe module: " + name)
+		}
+		if m.User == "" {
+			return errors.New("`user` is mandatory for probe module: " + name)
+		}
+		if m.Rule != "" {
+			if _, ok := rules.get(m.Rule); !ok {
+				return fmt.Errorf("unknown rule file for probe module %s: %s", name, m.Rule)
+			}
+		}
+		if m.Timeout < 0 {
+			return errors.New("`timeout` must not be negative for probe module: " + name)
+		}
+	}
+	return nil
+}
+
+type probeHandler struct {
+	config *probeConfig
+}
+
+// newProbeHandler returns a handler of /probe?target=<bmc>&module=<module>.
+// It traverses Redfish data of the target BMC on demand, and returns its metrics.
+func newProbeHandler(pc *probeConfig) http.Handler {
+	return probeHandler{config: pc}
+}
+
+func (h probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	moduleName := r.URL.Query().Get("module")
+	if moduleName == "" {
+		moduleName = defaultProbeModule
+	}
+	module, ok := h.config.Modules[moduleName]
+	if !ok {
+		http.Error(w, "unknown module: "+moduleName, http.StatusBadRequest)
+		return
+	}
+
+	host, port, err := splitTarget(target, module)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	if module.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(module.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	registry, err := probe(ctx, host, port, module)
+	if err != nil {
+		log.Error("failed to probe", map[string]interface{}{
+			"target":    target,
+			"module":    moduleName,
+			log.FnError: err,
+		})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	metricsHandler(registry).ServeHTTP(w, r)
+}
+
+// splitTarget splits target into the host and the port of the BMC.
+// target may have a port, e.g. "10.0.0.1:8443"; if it does not, the port of the module is used.
+// It is an error if both have ports and they differ.
+func splitTarget(target string, module *probeModule) (string, string, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		// target has no port.
+		return target, module.Port, nil
+	}
+	if host == "" || port == "" {
+		return "", "", fmt.Errorf("invalid target: %s", target)
+	}
+	if module.Port != "" && module.Port != port {
+		return "", "", fmt.Errorf("port of target %s conflicts with port %s of the module", target, module.Port)
+	}
+	return host, port, nil
+}
+
+// probe traverses the BMC at host and port once, and returns a registry holding the result.
+func probe(ctx context.Context, host, port string, module *probeModule) (*prometheus.Registry, error) {
+	ac := &config.AddressConfig{
+		IPv4: config.IPv4Config{Address: host},
+	}
+	uc := &config.UserConfig{
+		Support: config.Credentials{Password: config.BMCPassword{Raw: module.Password}},
+	}
+	cc := clientConfig(ac, uc)
+	cc.User = module.User
+	cc.Port = port
+
+	client, err := redfish.NewRedfishClient(cc)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := prometheus.NewRegistry()
+	var ruleGetter redfish.RuleGetter
+	if module.Rule != "" {
+		ruleGetter = rules.getter(module.Rule)
+	} else {
+		selector := newDellRuleSelector(client, "")
+		if err := registry.Register(selector); err != nil {
+			return nil, err
+		}
+		ruleGetter = selector.getRule
+	}
+
+	collector, err := redfish.NewCollector(ruleGetter, client)
+	if err != nil {
+		return nil, err
+	}
+	collector.Update(ctx)
+
+	if err := registry.Register(collector); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProbeConfig(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{
+			name: "valid",
+			content: `
+modules:
+  default:
+    user: support
+    password: secret
+  qemu:
+    user: support
+    password: secret
+    rule: qemu.yml
+    timeout: 60
+`,
+			valid: true,
+		},
+		{
+			name:    "no modules",
+			content: `modules: {}`,
+			valid:   false,
+		},
+		{
+			name: "no user",
+			content: `
+modules:
+  default:
+    password: secret
+`,
+			valid: false,
+		},
+		{
+			name: "unknown rule",
+			content: `
+modules:
+  default:
+    user: support
+    rule: no_such_rule.yml
+`,
+			valid: false,
+		},
+	}
+
+	dir := t.TempDir()
+	for _, tc := range testcases {
+		filename := filepath.Join(dir, strings.ReplaceAll(tc.name, " ", "_")+".yml")
+		if err := os.WriteFile(filename, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		pc, err := loadProbeConfig(filename)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: invalid config was accepted: %+v", tc.name, pc)
+		}
+	}
+}
+
+func TestProbeHandler(t *testing.T) {
+	t.Parallel()
+
+	pages := map[string]interface{}{
+		"/redfish/v1": map[string]interface{}{
+			"Systems": map[string]interface{}{"@odata.id": "/redfish/v1/Systems/System.Embedded.1/Processors/CPU.Socket.1"},
+		},
+		"/redfish/v1/Systems/System.Embedded.1/Processors/CPU.Socket.1": map[string]interface{}{
+			"Status": map[string]interface{}{"Health": "Warning"},
+		},
+	}
+	bmcHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "monitor" || password != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+	bmc := httptest.NewTLSServer(bmcHandler)
+	defer bmc.Close()
+
+	u, err := url.Parse(bmc.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same BMC on the IPv6 loopback address, if available.
+	var u6 *url.URL
+	if ln, err := net.Listen("tcp", "[::1]:0"); err == nil {
+		bmc6 := httptest.NewUnstartedServer(bmcHandler)
+		bmc6.Listener = ln
+		bmc6.StartTLS()
+		defer bmc6.Close()
+		u6, err = url.Parse(bmc6.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	port6 := ""
+	if u6 != nil {
+		port6 = u6.Port()
+	}
+
+	handler := newProbeHandler(&probeConfig{
+		Modules: map[string]*probeModule{
+			"qemu": {
+				User:     "monitor",
+				Password: "secret",
+				Rule:     "qemu.yml",
+			},
+			"port": {
+				User:     "monitor",
+				Password: "secret",
+				Port:     u.Port(),
+				Rule:     "qemu.yml",
+			},
+			"port6": {
+				User:     "monitor",
+				Password: "secret",
+				Port:     port6,
+				Rule:     "qemu.yml",
+			},
+			"otherport": {
+				User:     "monitor",
+				Password: "secret",
+				Port:     "1",
+				Rule:     "qemu.yml",
+			},
+		},
+	})
+
+	get := func(query string) (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/probe?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		body, err := io.ReadAll(w.Result().Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w.Result().StatusCode, string(body)
+	}
+
+	status, body := get("target=" + url.QueryEscape(u.Host) + "&module=qemu")
+	if status != http.StatusOK {
+		t.Fatal("probe failed:", status, body)
+	}
+	expected := `hw_processor_status_health{processor="CPU.Socket.1",system="System.Embedded.1"} 1`
+	if !strings.Contains(body, expected) {
+		t.Error("probe did not return metrics of the target; expected:", expected, "actual:", body)
+	}
+	if !strings.Contains(body, "hw_up 1") {
+		t.Error("probe did not return hw_up 1:", body)
+	}
+
+	// The port is taken from the module if the target does not have one.
+	if status, body := get("target=" + url.QueryEscape(u.Hostname()) + "&module=port"); status != http.StatusOK || !strings.Contains(body, "hw_up 1") {
+		t.Error("probe with the port of the module failed:", status, body)
+	}
+	if status, body := get("target=" + url.QueryEscape(u.Host) + "&module=port"); status != http.StatusOK || !strings.Contains(body, "hw_up 1") {
+		t.Error("probe with the same ports failed:", status, body)
+	}
+	if status, _ := get("target=" + url.QueryEscape(u.Host) + "&module=otherport"); status != http.StatusBadRequest {
+		t.Error("probe with conflicting ports was not rejected:", status)
+	}
+
+	// IPv6 addresses are bracketed in URLs whether the target has a port or not.
+	if u6 != nil {
+		if status, body := get("target=" + url.QueryEscape(u6.Host) + "&module=qemu"); status != http.StatusOK || !strings.Contains(body, "hw_up 1") {
+			t.Error("probe of IPv6 target with port failed:", status, body)
+		}
+		if status, body := get("target=" + url.QueryEscape(u6.Hostname()) + "&module=port6"); status != http.StatusOK || !strings.Contains(body, "hw_up 1") {
+			t.Error("probe of bare IPv6 target failed:", status, body)
+		}
+	} else {
+		t.Log("IPv6 is not available; skip IPv6 targets")
+	}
+
+	if status, _ := get("module=qemu"); status != http.StatusBadRequest {
+		t.Error("probe without target was not rejected:", status)
+	}
+	if status, _ := get("target=" + url.QueryEscape(u.Host)); status != http.StatusBadRequest {
+		t.Error("probe with unknown default module was not rejected:", status)
+	}
+}
@@ -19,7 +19,8 @@ func (l logger) Println(v ...interface{}) {
 	log.Error(fmt.Sprint(v...), nil)
 }
 
-func startExporter(ruleGetter redfish.RuleGetter, client redfish.Client) error {
+// handleMetrics registers the collector of Redfish data to the registry, and starts updating metrics periodically.
+func handleMetrics(registry *prometheus.Registry, ruleGetter redfish.RuleGetter, client redfish.Client) error {
 	collector, err := redfish.NewCollector(ruleGetter, client)
 	if err != nil {
 		return err
@@ -36,19 +37,19 @@ func startExporter(ruleGetter redfish.RuleGetter, client redfish.Client) error {
 		}
 	})
 
-	registry := prometheus.NewRegistry()
-	err = registry.Register(collector)
-	if err != nil {
-		return err
-	}
+	return registry.Register(collector)
+}
 
-	handler := promhttp.HandlerFor(registry,
+// metricsHandler returns the handler of /metrics.
+func metricsHandler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(registry,
 		promhttp.HandlerOpts{
 			ErrorLog:      logger{},
 			ErrorHandling: promhttp.ContinueOnError,
 		})
-	mux := http.NewServeMux()
-	mux.Handle("/metrics", handler)
+}
+
+func startExporter(mux *http.ServeMux) error {
 	serv := &well.HTTPServer{
 		Server: &http.Server{
 			Addr:    opts.listenAddress,
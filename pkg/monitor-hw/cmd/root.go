@@ -3,21 +3,33 @@ package cmd
 import (
 	"context"
 	"errors"
-	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/setup-hw/config"
 	"github.com/cybozu-go/setup-hw/lib"
 	"github.com/cybozu-go/setup-hw/redfish"
 	"github.com/cybozu-go/well"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 )
 
 var opts struct {
-	listenAddress string
-	interval      int
-	resetInterval int
-	noResetFile   string
+	listenAddress      string
+	interval           int
+	resetInterval      int
+	noResetFile        string
+	parallelism        int
+	requestTimeout     int
+	maxAttempts        int
+	retryableStatus    []int
+	probeConfig        string
+	probeOnly          bool
+	rulesDir           string
+	dellRuleVersion    string
+	profile            string
+	complianceInterval int
 }
 
 const (
@@ -25,6 +37,10 @@ const (
 	defaultInterval      = 60
 	defaultResetInterval = 24
 	defaultNoReset       = "/var/lib/setup-hw/no-reset"
+	defaultParallelism   = 4
+	defaultMaxAttempts   = 3
+
+	defaultComplianceInterval = 60
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -44,67 +60,65 @@ var rootCmd = &cobra.Command{
 	},
 
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ac, uc, err := config.LoadConfig()
-		if err != nil {
-			return err
+		if opts.probeOnly && opts.probeConfig == "" {
+			return errors.New("--probe-only requires --probe-config")
 		}
-
-		vendor, err := lib.DetectVendor()
-		if err != nil {
-			return err
+		if opts.requestTimeout <= 0 {
+			return errors.New("--request-timeout must be positive")
 		}
+		if opts.maxAttempts < 1 {
+			return errors.New("--max-attempts must be at least 1")
+		}
+
+		mux := http.NewServeMux()
+		registry := prometheus.NewRegistry()
 
-		var monitor func(context.Context) error
-		var client redfish.Client
-		var ruleGetter redfish.RuleGetter
-		switch vendor {
-		case lib.QEMU:
-			monitor = monitorQEMU
-			client = redfish.NewMockClient(redfish.DummyRedfishFile)
-			ruleFile := "qemu.yml"
-			rule, ok := redfish.Rules[ruleFile]
-			if !ok {
-				return errors.New("unknown rule file: " + ruleFile)
+		if opts.rulesDir != "" {
+			if err := rules.load(opts.rulesDir); err != nil {
+				return err
 			}
-			ruleGetter = func(context.Context) (*redfish.CollectRule, error) {
-				return rule, nil
+			if err := registry.Register(rules); err != nil {
+				return err
 			}
+			well.Go(rules.watch)
+		}
 
-		case lib.Dell:
-			monitor = monitorDell
-			cc := &redfish.ClientConfig{
-				AddressConfig: ac,
-				UserConfig:    uc,
-				NoEscape:      true,
+		if opts.dellRuleVersion != "" {
+			if _, err := selectDellRule(opts.dellRuleVersion, rules.names()); err != nil {
+				return err
 			}
-			cl, err := redfish.NewRedfishClient(cc)
+		}
+
+		if opts.probeConfig != "" {
+			pc, err := loadProbeConfig(opts.probeConfig)
 			if err != nil {
 				return err
 			}
-			client = cl
-			ruleGetter = func(ctx context.Context) (*redfish.CollectRule, error) {
-				version, err := cl.GetVersion(ctx)
-				if err != nil {
-					return nil, err
-				}
-				ruleFile := fmt.Sprintf("dell_redfish_%s.yml", version)
-				rule, ok := redfish.Rules[ruleFile]
-				if !ok {
-					return nil, errors.New("unknown rule file: " + ruleFile)
-				}
-				return rule, nil
+			mux.Handle("/probe", newProbeHandler(pc))
+		}
+
+		if !opts.probeOnly {
+			monitor, ruleGetter, client, err := localMonitor(registry)
+			if err != nil {
+				return err
 			}
+			err = handleMetrics(registry, ruleGetter, client)
+			if err != nil {
+				return err
+			}
+			well.Go(monitor)
+		}
 
-		default:
-			return errors.New("unsupported vendor hardware")
+		// In probe-only mode, /metrics is served only to report reloads of rules.
+		if !opts.probeOnly || opts.rulesDir != "" {
+			mux.Handle("/metrics", metricsHandler(registry))
 		}
 
-		err = startExporter(ruleGetter, client)
+		err := startExporter(mux)
 		if err != nil {
 			return err
 		}
 
-		well.Go(monitor)
 		well.Stop()
 		err = well.Wait()
 		if err != nil && !well.IsSignaled(err) {
@@ -114,6 +128,75 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// localMonitor prepares monitoring of the server where monitor-hw runs.
+// Vendor-specific metrics are registered to registry.
+func localMonitor(registry *prometheus.Registry) (func(context.Context) error, redfish.RuleGetter, redfish.Client, error) {
+	ac, uc, err := config.LoadConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	vendor, err := lib.DetectVendor()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	switch vendor {
+	case lib.QEMU:
+		client := redfish.NewMockClient(redfish.DummyRedfishFile)
+		ruleFile := "qemu.yml"
+		if _, ok := rules.get(ruleFile); !ok {
+			return nil, nil, nil, errors.New("unknown rule file: " + ruleFile)
+		}
+		return monitorQEMU, rules.getter(ruleFile), client, nil
+
+	case lib.Dell:
+		client, err := redfish.NewRedfishClient(clientConfig(ac, uc))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		selector := newDellRuleSelector(client, opts.dellRuleVersion)
+		if err := registry.Register(selector); err != nil {
+			return nil, nil, nil, err
+		}
+
+		var compliance *complianceCollector
+		if opts.complianceInterval > 0 {
+			// Monitoring goes on without the check, e.g. if the hardware profile is broken.
+			compliance, err = dellComplianceCollector(ac, uc)
+			if err != nil {
+				log.Error("failed to prepare compliance check of hardware settings; disabled", map[string]interface{}{
+					log.FnError: err,
+				})
+				compliance = nil
+			} else if err := registry.Register(compliance); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		monitor := func(ctx context.Context) error {
+			return monitorDell(ctx, compliance)
+		}
+		return monitor, selector.getRule, client, nil
+	}
+
+	return nil, nil, nil, errors.New("unsupported vendor hardware")
+}
+
+// clientConfig returns the configuration of Redfish client specified by the command-line flags.
+func clientConfig(ac *config.AddressConfig, uc *config.UserConfig) *redfish.ClientConfig {
+	retryPolicy := redfish.DefaultRetryPolicy()
+	retryPolicy.MaxAttempts = opts.maxAttempts
+	retryPolicy.RetryableStatusCodes = opts.retryableStatus
+	return &redfish.ClientConfig{
+		AddressConfig:  ac,
+		UserConfig:     uc,
+		NoEscape:       true,
+		Parallelism:    opts.parallelism,
+		RetryPolicy:    retryPolicy,
+		RequestTimeout: time.Duration(opts.requestTimeout) * time.Second,
+	}
+}
+
 // Execute executes monitor-hw
 func Execute() {
 	err := rootCmd.Execute()
@@ -127,4 +210,14 @@ func init() {
 	rootCmd.Flags().IntVar(&opts.interval, "interval", defaultInterval, "interval of collecting metrics in seconds")
 	rootCmd.Flags().IntVar(&opts.resetInterval, "reset-interval", defaultResetInterval, "interval of resetting iDRAC in hours (dell servers only)")
 	rootCmd.Flags().StringVar(&opts.noResetFile, "no-reset", defaultNoReset, "path of the no-reset file")
+	rootCmd.Flags().IntVar(&opts.parallelism, "parallelism", defaultParallelism, "maximum number of concurrent requests to BMC")
+	rootCmd.Flags().IntVar(&opts.requestTimeout, "request-timeout", int(redfish.DefaultRequestTimeout/time.Second), "timeout of each request to BMC in seconds")
+	rootCmd.Flags().IntVar(&opts.maxAttempts, "max-attempts", defaultMaxAttempts, "maximum number of attempts of each request to BMC")
+	rootCmd.Flags().IntSliceVar(&opts.retryableStatus, "retryable-status", redfish.DefaultRetryPolicy().RetryableStatusCodes, "HTTP status codes to retry requests to BMC")
+	rootCmd.Flags().StringVar(&opts.probeConfig, "probe-config", "", "path of the configuration file of probe modules; enables /probe endpoint")
+	rootCmd.Flags().BoolVar(&opts.probeOnly, "probe-only", false, "serve /probe endpoint only, without monitoring the local server")
+	rootCmd.Flags().StringVar(&opts.dellRuleVersion, "dell-rule-version", "", "Redfish version of the rule used regardless of the version of iDRAC (dell servers only)")
+	rootCmd.Flags().StringVar(&opts.profile, "profile", config.ProfileFile, "hardware profile to check compliance of settings (dell servers only)")
+	rootCmd.Flags().IntVar(&opts.complianceInterval, "compliance-interval", defaultComplianceInterval, "interval of checking compliance of settings in minutes; 0 disables it (dell servers only)")
+	rootCmd.Flags().StringVar(&opts.rulesDir, "rules-dir", "", "directory of collection rule files to be loaded in addition to the embedded rules")
 }
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cybozu-go/log"
+	"github.com/cybozu-go/setup-hw/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const rulesCheckInterval = 10 * time.Second
+
+// ruleStore holds the collection rules available to monitor-hw.
+// The rules loaded from the rules directory take precedence over the embedded ones in redfish.Rules.
+type ruleStore struct {
+	dir         string
+	loaded      atomic.Value // map[string]*redfish.CollectRule
+	fingerprint string
+	reloads     *prometheus.CounterVec
+	lastSuccess prometheus.Gauge
+}
+
+// rules is the rule store of monitor-hw.
+// It holds only the embedded rules until the rules directory is loaded.
+var rules = newRuleStore()
+
+func newRuleStore() *ruleStore {
+	s := &ruleStore{
+		reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "hw",
+			Subsystem: "rules",
+			Name:      "reloads_total",
+			Help:      "Number of reloads of the rules directory by result.",
+		}, []string{"result"}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "hw",
+			Subsystem: "rules",
+			Name:      "last_reload_success",
+			Help:      "1 if the last reload of the rules directory succeeded, 0 otherwise.",
+		}),
+	}
+	s.loaded.Store(map[string]*redfish.CollectRule{})
+	return s
+}
+
+// get returns the rule of the file name.
+func (s *ruleStore) get(name string) (*redfish.CollectRule, bool) {
+	if rule, ok := s.loaded.Load().(map[string]*redfish.CollectRule)[name]; ok {
+		return rule, true
+	}
+	rule, ok := redfish.Rules[name]
+	return rule, ok
+}
+
+// names returns the sorted file names of all the available rules.
+func (s *ruleStore) names() []string {
+	all := make(map[string]*redfish.CollectRule)
+	for name, rule := range redfish.Rules {
+		all[name] = rule
+	}
+	for name, rule := range s.loaded.Load().(map[string]*redfish.CollectRule) {
+		all[name] = rule
+	}
+	return ruleNames(all)
+}
+
+// getter returns a RuleGetter which always looks up the rule of the file name.
+// It reflects reloads of the rules directory.
+func (s *ruleStore) getter(name string) redfish.RuleGetter {
+	return func(context.Context) (*redfish.CollectRule, error) {
+		rule, ok := s.get(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown rule file: %s", name)
+		}
+		return rule, nil
+	}
+}
+
+// load loads the rules in dir for the first time.
+func (s *ruleStore) load(dir string) error {
+	s.dir = dir
+	fingerprint, err := s.dirFingerprint()
+	if err != nil {
+		return err
+	}
+	loaded, err := redfish.LoadRules(dir)
+	if err != nil {
+		return err
+	}
+	s.fingerprint = fingerprint
+	s.loaded.Store(loaded)
+	s.lastSuccess.Set(1)
+	log.Info("loaded rules", map[string]interface{}{
+		"dir":   dir,
+		"rules": ruleNames(loaded),
+	})
+	return nil
+}
+
+// reload reloads the rules directory.
+// If the new rules are invalid, the previous ones are kept.
+func (s *ruleStore) reload() {
+	fingerprint, err := s.dirFingerprint()
+	if err == nil {
+		var loaded map[string]*redfish.CollectRule
+		loaded, err = redfish.LoadRules(s.dir)
+		if err == nil {
+			s.fingerprint = fingerprint
+			s.loaded.Store(loaded)
+			s.reloads.WithLabelValues("success").Inc()
+			s.lastSuccess.Set(1)
+			log.Info("reloaded rules", map[string]interface{}{
+				"dir":   s.dir,
+				"rules": ruleNames(loaded),
+			})
+			return
+		}
+	}
+
+	// Do not retry until the files are changed again.
+	s.fingerprint = fingerprint
+	s.reloads.WithLabelValues("failure").Inc()
+	s.lastSuccess.Set(0)
+	log.Error("failed to reload rules; keep using the previous rules", map[string]interface{}{
+		"dir":       s.dir,
+		log.FnError: err,
+	})
+}
+
+// watch reloads the rules directory on SIGHUP or when the rule files are changed.
+func (s *ruleStore) watch(ctx context.Context) error {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sighup:
+			s.reload()
+		case <-time.After(rulesCheckInterval):
+			fingerprint, err := s.dirFingerprint()
+			if err == nil && fingerprint == s.fingerprint {
+				continue
+			}
+			s.reload()
+		}
+	}
+}
+
+// dirFingerprint returns a string which changes when a rule file in the directory is added, removed, or modified.
+func (s *ruleStore) dirFingerprint() (string, error) {
+	filenames, err := filepath.Glob(filepath.Join(s.dir, "*"+redfish.RuleFileExt))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, filename := range filenames {
+		// Stat follows symbolic links, so that updates of Kubernetes ConfigMaps are detected.
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d\n", filepath.Base(filename), fi.Size(), fi.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// Describe implements prometheus.Collector.
+func (s *ruleStore) Describe(ch chan<- *prometheus.Desc) {
+	s.reloads.Describe(ch)
+	s.lastSuccess.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (s *ruleStore) Collect(ch chan<- prometheus.Metric) {
+	s.reloads.Collect(ch)
+	s.lastSuccess.Collect(ch)
+}
+
+func ruleNames(rules map[string]*redfish.CollectRule) []string {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRuleStore(t *testing.T) {
+	t.Parallel()
+
+	valid, err := os.ReadFile("../../../testdata/redfish_collect.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "custom.yml")
+	if err := os.WriteFile(filename, valid, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newRuleStore()
+	if _, ok := s.get("custom.yml"); ok {
+		t.Error("rule is available before loading")
+	}
+	if err := s.load(dir); err != nil {
+		t.Fatal(err)
+	}
+	rule, ok := s.get("custom.yml")
+	if !ok {
+		t.Fatal("loaded rule is not available")
+	}
+	if _, ok := s.get("qemu.yml"); !ok {
+		t.Error("embedded rule is not available")
+	}
+
+	// An invalid reload keeps the previous rules.
+	if err := os.WriteFile(filename, []byte("Traverse: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.reload()
+	if r, _ := s.get("custom.yml"); r != rule {
+		t.Error("previous rule was not kept")
+	}
+	if v := testutil.ToFloat64(s.lastSuccess); v != 0 {
+		t.Errorf("unexpected last_reload_success: %v", v)
+	}
+	if v := testutil.ToFloat64(s.reloads.WithLabelValues("failure")); v != 1 {
+		t.Errorf("unexpected failed reloads: %v", v)
+	}
+
+	// A valid reload replaces the rules.
+	if err := os.WriteFile(filename, valid, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filename, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	s.reload()
+	if r, _ := s.get("custom.yml"); r == rule {
+		t.Error("rule was not reloaded")
+	}
+	if v := testutil.ToFloat64(s.lastSuccess); v != 1 {
+		t.Errorf("unexpected last_reload_success: %v", v)
+	}
+
+	getter := s.getter("no_such_rule.yml")
+	if _, err := getter(context.Background()); err == nil {
+		t.Error("unknown rule was returned")
+	}
+}
@@ -101,6 +101,23 @@ var Rules = map[string]*CollectRule{
 				{{- end }}
 			},
 		},
+		{{- if $value.TypeRules }}
+		TypeRules: []*TypeRule{
+			{{- range $value.TypeRules }}
+			{
+				Name: {{ printf "%q" .Name }},
+				Values: map[string]float64{
+					{{- range $k, $v := .Values }}
+					{{ printf "%q" $k }}: {{ $v }},
+					{{- end }}
+				},
+				{{- with .Default }}
+				Default: floatPtr({{ . }}),
+				{{- end }}
+			},
+			{{- end }}
+		},
+		{{- end }}
 		MetricRules: []*MetricRule{
 			{{- range $value.MetricRules }}
 			{
@@ -112,6 +129,13 @@ var Rules = map[string]*CollectRule{
 						Name: {{ printf "%q" .Name }},
 						Help: {{ printf "%q" .Help }},
 						Type: {{ printf "%q" .Type }},
+						{{- if .Labels }}
+						Labels: []string{
+							{{- range .Labels }}
+							{{ printf "%q" . }},
+							{{- end }}
+						},
+						{{- end }}
 					},
 					{{- end }}
 				},
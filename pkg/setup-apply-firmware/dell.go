@@ -1,31 +1,29 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"errors"
 	"fmt"
-	"os/exec"
 	"strings"
 	"time"
 
 	"github.com/cybozu-go/log"
-	"github.com/cybozu-go/well"
+	"github.com/cybozu-go/setup-hw/idrac"
 )
 
+// updateInterval is the time to wait between updates.
+// if the next `idracadm7 update` is executed immediately after the previous one, it will fail.
+var updateInterval = time.Second * 10
+
 func setupDell(ctx context.Context, files []string) error {
+	return applyDell(ctx, idrac.NewRacadm(), files)
+}
+
+func applyDell(ctx context.Context, r idrac.IDRAC, files []string) error {
 	for _, f := range files {
-		cmd := well.CommandContext(ctx, "/opt/dell/srvadmin/bin/idracadm7", "update", "-f", f)
-		buf := bytes.Buffer{}
-		cmd.Stdout = &buf
-		cmd.Stderr = &buf
-		err := cmd.Run()
-		// we cannot use exit status to detect errors because `idracadm7 update` returns nonzero status even in case of successful update initiation.
-		var exitError *exec.ExitError
-		if err != nil && !errors.As(err, &exitError) {
-			return fmt.Errorf("racadm update failed at file %s: %w", f, err)
+		msg, err := r.Update(ctx, f)
+		if err != nil {
+			return err
 		}
-		msg := buf.String()
 		if err = checkRacadmOutput(msg, f); err != nil {
 			return err
 		}
@@ -33,8 +31,11 @@ func setupDell(ctx context.Context, files []string) error {
 			"file": f,
 		})
 
-		// if the next `idracadm7 update` is executed immediately after the previous one, it will fail.
-		time.Sleep(time.Second * 10)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(updateInterval):
+		}
 	}
 
 	return nil
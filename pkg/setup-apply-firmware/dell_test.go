@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"testing"
+
+	"github.com/cybozu-go/setup-hw/idrac"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestCheckRacadmOutput(t *testing.T) {
@@ -25,3 +30,22 @@ RAC987: Update initiated.
 		t.Fatal(err)
 	}
 }
+
+func TestApplyDell(t *testing.T) {
+	updateInterval = 0
+
+	f := idrac.NewFake(nil)
+	files := []string{"BIOS.exe", "iDRAC.exe"}
+	if err := applyDell(context.Background(), f, files); err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(f.Updates(), files) {
+		t.Error("unexpected updates:", f.Updates())
+	}
+
+	f = idrac.NewFake(nil)
+	f.SetError("Update", errors.New("connection refused"))
+	if err := applyDell(context.Background(), f, files); err == nil {
+		t.Error("applyDell should fail")
+	}
+}
@@ -4,151 +4,16 @@ import (
 	"context"
 	"errors"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/setup-hw/config"
+	"github.com/cybozu-go/setup-hw/idrac"
+	"github.com/cybozu-go/setup-hw/lib"
 	"github.com/cybozu-go/well"
-	"gopkg.in/ini.v1"
 )
 
-const (
-	racadmPath = "/opt/dell/srvadmin/bin/idracadm7"
-
-	retryCount = 5
-)
-
-func racadm(ctx context.Context, args ...string) (string, error) {
-	cmd := well.CommandContext(ctx, racadmPath, args...)
-	out, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-	return string(out), nil
-}
-
-func racadmRetry(ctx context.Context, args ...string) error {
-	retries := 0
-RETRY:
-	cmd := well.CommandContext(ctx, racadmPath, args...)
-	err := cmd.Run()
-	if err == nil {
-		time.Sleep(1 * time.Second)
-		return nil
-	}
-
-	retries++
-	if retries == retryCount {
-		return err
-	}
-
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(10 * time.Second):
-	}
-	goto RETRY
-}
-
-func racadmRetrySilent(ctx context.Context, args ...string) error {
-	retries := 0
-RETRY:
-	cmd := exec.CommandContext(ctx, racadmPath, args...)
-	out, err := cmd.CombinedOutput()
-	if err == nil {
-		time.Sleep(1 * time.Second)
-		return nil
-	}
-
-	retries++
-	if retries == retryCount {
-		log.Error("idracadm7 failed", map[string]interface{}{
-			log.FnError: err,
-			"output":    string(out),
-		})
-		return err
-	}
-
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(10 * time.Second):
-	}
-	goto RETRY
-}
-
-// racadmGetConfig returns a string corresponding to the given key.
-// In many cases 'idracadm7 get KEY' returns INI format output,
-// but in some cases it returns the value not conforming to INI format.
-//
-// case1: conform to INI format
-//     $ sudo idracadm7 get iDRAC.SNMP.AgentEnable
-//     [Key=iDRAC.Embedded.1#SNMP.1]
-//     AgentEnable=Enabled
-//
-// case2: not conform to INI format. return only value.
-//     $ sudo idracadm7 get System.ServerPwr.PSRapidOn
-//     Enabled
-//
-func racadmGetConfig(ctx context.Context, key string) (string, error) {
-	cmd := well.CommandContext(ctx, racadmPath, "get", key)
-	cmd.Severity = log.LvDebug
-	out, err := cmd.Output()
-	if err != nil {
-		return "", err
-	}
-
-	return parseRacadmGetOutput(string(out), key)
-}
-
-func parseRacadmGetOutput(out, key string) (string, error) {
-	if !strings.HasPrefix(out, "[") {
-		return strings.TrimSpace(out), nil
-	}
-
-	cfg, err := ini.Load([]byte(out))
-	if err != nil {
-		return "", err
-	}
-
-	var sectionName string
-	for _, name := range cfg.SectionStrings() {
-		if name != ini.DefaultSection {
-			sectionName = name
-			break
-		}
-	}
-
-	section := cfg.Section(sectionName)
-	keys := section.Keys()
-	if len(keys) == 0 {
-		return "", errors.New("unexpected output for " + key)
-	}
-
-	return keys[0].String(), nil
-}
-
-// racadmSetConfig check the current value of key and compares it to value.
-// If the current value is the same as value, this returns (false, nil).
-// Otherwise, this sets key to value and returns (true, nil).
-func racadmSetConfig(ctx context.Context, key, value string) (bool, error) {
-	cur, err := racadmGetConfig(ctx, key)
-	if err != nil {
-		return false, err
-	}
-	if cur == value {
-		return false, nil
-	}
-
-	err = racadmRetry(ctx, "set", key, value)
-	if err != nil {
-		return false, err
-	}
-	return true, nil
-}
-
 var waitKeys = []string{
 	"BIOS.SysProfileSettings.SysProfile",
 	"BIOS.ProcSettings.LogicalProc",
@@ -167,16 +32,16 @@ var waitKeys = []string{
 	"iDRAC.VirtualConsole.PluginType",
 }
 
-func iDRACWait(ctx context.Context) error {
+func iDRACWait(ctx context.Context, r idrac.IDRAC) error {
 	log.Info("waiting iDRAC...", nil)
 	for i := 0; i < 60; i++ {
-		out, _ := racadm(ctx, "get", "iDRAC.Info.Name")
-		if !strings.Contains(out, "Name=iDRAC") {
+		name, _ := r.Get(ctx, "iDRAC.Info.Name")
+		if !strings.HasPrefix(name, "iDRAC") {
 			goto NOTREADY
 		}
 
 		for _, key := range waitKeys {
-			_, err := racadmGetConfig(ctx, key)
+			_, err := r.Get(ctx, key)
 			if err != nil {
 				goto NOTREADY
 			}
@@ -195,289 +60,192 @@ func iDRACWait(ctx context.Context) error {
 }
 
 type dellConfigurator struct {
-	addressConfig *config.AddressConfig
-	userConfig    *config.UserConfig
-	queued        bool
+	idrac   idrac.IDRAC
+	desired *idrac.Desired
+	queued  bool
+
+	// safetyWait is the time to wait after iDRAC gets ready.
+	safetyWait time.Duration
+
+	// dryRun makes the configurator only record settings in plan without changing them.
+	dryRun bool
+	plan   *plan
 }
 
 func (dc *dellConfigurator) Run(ctx context.Context) error {
-	if err := iDRACWait(ctx); err != nil {
+	if err := iDRACWait(ctx, dc.idrac); err != nil {
 		return err
 	}
 
-	// for extra safety
-	time.Sleep(1 * time.Minute)
+	if !dc.dryRun {
+		// for extra safety
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(dc.safetyWait):
+		}
+	}
 
-	out, err := racadm(ctx, "jobqueue", "view")
+	out, err := dc.idrac.JobQueueView(ctx)
 	if err != nil {
 		return err
 	}
 	if strings.Contains(out, "Status=Scheduled") {
-		dc.queued = true
-		log.Warn("scheduled jobs exist", map[string]interface{}{
-			"output": out,
-		})
-		return errors.New("scheduled jobs are queued")
-	}
-
-	if err := dc.configBIOS(ctx); err != nil {
-		return err
-	}
-	if err := dc.configSystem(ctx); err != nil {
-		return err
-	}
-	if err := dc.configiDRAC(ctx); err != nil {
-		return err
-	}
-
-	if dc.queued {
-		if _, err = racadm(ctx, "jobqueue", "create", "BIOS.Setup.1-1"); err != nil {
-			return err
+		if dc.dryRun {
+			// Go on to show what would be changed after the jobs.
+			dc.plan.ScheduledJobs = true
+		} else {
+			dc.queued = true
+			log.Warn("scheduled jobs exist", map[string]interface{}{
+				"output": out,
+			})
+			return errors.New("scheduled jobs are queued")
 		}
 	}
 
-	return nil
-}
-
-func (dc *dellConfigurator) enqueueConfig(ctx context.Context, key, value string) error {
-	updated, err := racadmSetConfig(ctx, key, value)
+	settings, err := dc.desired.Settings(ctx, dc.idrac)
 	if err != nil {
 		return err
 	}
-	if updated {
-		dc.queued = true
-	}
-	return nil
-}
-
-func (dc *dellConfigurator) configBIOS(ctx context.Context) error {
-	if err := dc.configPerformance(ctx); err != nil {
-		return err
-	}
-	if err := dc.configProcessor(ctx); err != nil {
-		return err
-	}
-	if err := dc.configTpm(ctx); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (dc *dellConfigurator) configPerformance(ctx context.Context) error {
-	return dc.enqueueConfig(ctx, "BIOS.SysProfileSettings.SysProfile", "PerfPerWattOptimizedOs")
-}
-
-func (dc *dellConfigurator) configProcessor(ctx context.Context) error {
-	return dc.enqueueConfig(ctx, "BIOS.ProcSettings.LogicalProc", "Disabled")
-}
-
-func (dc *dellConfigurator) configTpm(ctx context.Context) error {
-	val, err := racadmGetConfig(ctx, "BIOS.SysSecurity.TpmInfo")
-	if err != nil {
-		return err
-	}
-	switch {
-	case strings.Contains(val, "2.0"):
-		return dc.enqueueConfig(ctx, "BIOS.SysSecurity.TpmSecurity", "On")
-	case strings.Contains(val, "1.2"):
-		if err := dc.enqueueConfig(ctx, "BIOS.SysSecurity.TpmSecurity", "OnPbm"); err != nil {
+	for _, s := range settings {
+		if err := dc.configure(ctx, s); err != nil {
 			return err
 		}
-		key := "BIOS.SysSecurity.TpmStatus"
-		val, err := racadmGetConfig(ctx, key)
-		if err != nil {
+	}
+
+	if dc.queued && !dc.dryRun {
+		if err = dc.idrac.JobQueueCreate(ctx, "BIOS.Setup.1-1"); err != nil {
 			return err
 		}
-		if val == "Enabled, Activated" {
-			return nil
-		}
-		return dc.configTpmCommand(ctx, "Activate")
 	}
 
-	log.Warn("tpm not found", map[string]interface{}{
-		"tpminfo": val,
-	})
 	return nil
 }
 
-func (dc *dellConfigurator) configTpmCommand(ctx context.Context, action string) error {
-	return dc.enqueueConfig(ctx, "BIOS.SysSecurity.TpmCommand", action)
-}
-
-func (dc *dellConfigurator) configSystem(ctx context.Context) error {
-	if err := dc.configPowerSupply(ctx); err != nil {
-		return err
-	}
-	if err := dc.configFanSpeed(ctx); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (dc *dellConfigurator) configPowerSupply(ctx context.Context) error {
-	_, err := racadmSetConfig(ctx, "System.ServerPwr.PSRapidOn", "Disabled")
-	return err
-}
-
-// configFanSpeed adjusts fan speed calculation algorithm.
-// ref: https://www.dell.com/support/article/us/en/04/sln283419/adjusting-fan-speed-offset-in-dell-poweredge-12th-generation-servers?lang=en
-func (dc *dellConfigurator) configFanSpeed(ctx context.Context) error {
-	key := "System.ThermalSettings.FanSpeedOffset"
-	val, err := racadmGetConfig(ctx, key)
+// configure sets the key of s to the desired value if the current value differs.
+// Changes of BIOS settings are queued as a job applied at the next reboot.
+// It does nothing if the condition of s is not satisfied.
+func (dc *dellConfigurator) configure(ctx context.Context, s *idrac.Setting) error {
+	ok, err := s.Match(ctx, dc.idrac)
 	if err != nil {
 		return err
 	}
-
-	if val == "Low" {
+	if !ok {
 		return nil
 	}
 
-	return racadmRetry(ctx, "set", key, "0")
-}
-
-func (dc *dellConfigurator) configiDRAC(ctx context.Context) error {
-	if err := dc.configSNMP(ctx); err != nil {
-		return err
-	}
-	if err := dc.configNIC(ctx); err != nil {
-		return err
-	}
-	if err := dc.configIPMI(ctx); err != nil {
-		return err
-	}
-	if err := dc.configUsers(ctx); err != nil {
-		return err
-	}
-	if err := dc.configVirtualConsole(ctx); err != nil {
-		return err
+	if s.Secret {
+		// The secret cannot be read, so it is always set.
+		dc.plan.add(s.Key, "", "", true)
+		if dc.dryRun {
+			return nil
+		}
+		return dc.idrac.SetSecret(ctx, s.Key, s.WriteValue())
 	}
-	return nil
-}
 
-func (dc *dellConfigurator) configSNMP(ctx context.Context) error {
-	_, err := racadmSetConfig(ctx, "iDRAC.SNMP.AgentEnable", "Enabled")
-	return err
-}
-
-func (dc *dellConfigurator) configNIC(ctx context.Context) error {
-	if _, err := racadmSetConfig(ctx, "iDRAC.NIC.Selection", "Dedicated"); err != nil {
-		return err
-	}
-	if _, err := racadmSetConfig(ctx, "iDRAC.IPv4.DHCPEnable", "Disabled"); err != nil {
-		return err
-	}
-	cfg := dc.addressConfig.IPv4
-	if _, err := racadmSetConfig(ctx, "iDRAC.IPv4.Address", cfg.Address); err != nil {
-		return err
-	}
-	if _, err := racadmSetConfig(ctx, "iDRAC.IPv4.Netmask", cfg.Netmask); err != nil {
-		return err
-	}
-	if _, err := racadmSetConfig(ctx, "iDRAC.IPv4.Gateway", cfg.Gateway); err != nil {
+	cur, err := dc.idrac.Get(ctx, s.Key)
+	if err != nil {
 		return err
 	}
-	hname, err := os.Hostname()
+	updated, err := dc.apply(ctx, s.Key, cur, s.Value, s.WriteValue())
 	if err != nil {
 		return err
 	}
-	if _, err := racadmSetConfig(ctx, "iDRAC.NIC.DNSRacName", hname+"-idrac"); err != nil {
-		return err
+	if updated && s.Reboot() {
+		dc.queued = true
+		dc.plan.reboot(s.Key)
 	}
 	return nil
 }
 
-func (dc *dellConfigurator) configIPMI(ctx context.Context) error {
-	if _, err := racadmSetConfig(ctx, "iDRAC.IPMILan.PrivLimit", "3"); err != nil {
-		return err
-	}
-	key := "iDRAC.IPMILan.Enable"
-	value, err := racadmGetConfig(ctx, key)
-	if err != nil {
-		return err
+// apply records key in the plan, and sets key to setValue if current differs from desired.
+// setValue is what racadm takes for desired, e.g. "1" for "Enabled".
+// In dry-run mode, this does not set anything.
+func (dc *dellConfigurator) apply(ctx context.Context, key, current, desired, setValue string) (bool, error) {
+	changed := current != desired
+	dc.plan.add(key, current, desired, changed)
+	if !changed || dc.dryRun {
+		return changed, nil
 	}
-	if value == "Enabled" {
-		return nil
+
+	if err := dc.idrac.Set(ctx, key, setValue); err != nil {
+		return false, err
 	}
-	return racadmRetry(ctx, "set", key, "1")
+	return true, nil
 }
 
-func (dc *dellConfigurator) configUser(ctx context.Context, idx, name, priv, ipmiPriv string, cred config.Credentials) error {
-	// ipmipriv:
-	// - 1 Callback level
-	// - 2 User level
-	// - 3 Operator level
-	// - 4 Administrator level
-	// - 5 OEM Proprietary level
-	// - 15 No access
+const (
+	backendRacadm  = "racadm"
+	backendRedfish = "redfish"
 
-	prefix := "iDRAC.Users." + idx + "."
-	if _, err := racadmSetConfig(ctx, prefix+"Username", name); err != nil {
-		return err
-	}
-	if cred.Password.Raw != "" {
-		if err := racadmRetrySilent(ctx, "set", prefix+"Password", cred.Password.Raw); err != nil {
-			return err
-		}
-	} else {
-		if _, err := racadmSetConfig(ctx, prefix+"SHA256Password", cred.Password.Hash); err != nil {
-			return err
-		}
-		if _, err := racadmSetConfig(ctx, prefix+"SHA256PasswordSalt", cred.Password.Salt); err != nil {
-			return err
+	// defaultRedfishEndpoint is the address of iDRAC through OS to iDRAC pass-through.
+	defaultRedfishEndpoint = "https://169.254.0.1"
+)
+
+// newIDRAC returns the backend selected by --backend.
+// The Redfish backend accesses iDRAC as root with the raw password in the user configuration.
+func newIDRAC(uc *config.UserConfig) (idrac.IDRAC, error) {
+	if *flagBackend == backendRedfish {
+		password := uc.Root.Password.Raw
+		if password == "" {
+			return nil, errors.New("raw password of root is required for the Redfish backend")
 		}
+		return idrac.NewRedfish(*flagRedfishEndpoint, "root", password)
 	}
 
-	if _, err := racadmSetConfig(ctx, prefix+"Privilege", priv); err != nil {
-		return err
-	}
-	if _, err := racadmSetConfig(ctx, prefix+"IpmiLanPrivilege", ipmiPriv); err != nil {
-		return err
-	}
-	if _, err := racadmSetConfig(ctx, prefix+"IpmiSerialPrivilege", ipmiPriv); err != nil {
-		return err
-	}
-	if _, err := racadmSetConfig(ctx, prefix+"Enable", "Enabled"); err != nil {
-		return err
+	_, err := os.Stat(idrac.RacadmPath)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	return idrac.NewRacadm(), nil
 }
 
-func (dc *dellConfigurator) configUsers(ctx context.Context) error {
-	if err := dc.configUser(ctx, "2", "root", "0x1ff", "4", dc.userConfig.Root); err != nil {
-		return err
-	}
-	if err := dc.configUser(ctx, "3", "support", "0x11", "15", dc.userConfig.Support); err != nil {
-		return err
+// newDesired returns the desired state of this server.
+func newDesired(ac *config.AddressConfig, uc *config.UserConfig, profile *config.Profile) (*idrac.Desired, error) {
+	model, err := lib.DetectModel()
+	if err != nil {
+		return nil, err
 	}
-	if err := dc.configUser(ctx, "4", "power", "0x11", "3", dc.userConfig.Power); err != nil {
-		return err
+	hname, err := os.Hostname()
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-func (dc *dellConfigurator) configVirtualConsole(ctx context.Context) error {
-	_, err := racadmSetConfig(ctx, "iDRAC.VirtualConsole.PluginType", "2")
-	return err
+	return &idrac.Desired{
+		Profile:       profile,
+		AddressConfig: ac,
+		UserConfig:    uc,
+		Model:         model,
+		Hostname:      hname,
+	}, nil
 }
 
 // setupDell configures BIOS and iDRAC for Dell servers.
-func setupDell(ac *config.AddressConfig, uc *config.UserConfig) (bool, error) {
-	_, err := os.Stat(racadmPath)
+// If dryRun is true, it only returns the plan of changes.
+func setupDell(ac *config.AddressConfig, uc *config.UserConfig, profile *config.Profile, dryRun bool) (*plan, error) {
+	r, err := newIDRAC(uc)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	desired, err := newDesired(ac, uc, profile)
+	if err != nil {
+		return nil, err
 	}
 
 	configurator := &dellConfigurator{
-		addressConfig: ac,
-		userConfig:    uc,
+		idrac:      r,
+		desired:    desired,
+		safetyWait: 1 * time.Minute,
+		dryRun:     dryRun,
+		plan:       new(plan),
 	}
 	well.Go(configurator.Run)
 	well.Stop()
 	err = well.Wait()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	return configurator.queued, nil
+	return configurator.plan, nil
 }
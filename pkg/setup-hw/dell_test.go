@@ -1,30 +1,225 @@
 package main
 
 import (
+	"context"
 	"testing"
+
+	"github.com/cybozu-go/setup-hw/config"
+	"github.com/cybozu-go/setup-hw/idrac"
+	"github.com/google/go-cmp/cmp"
 )
 
-func TestParseRacadmGetOutput(t *testing.T) {
+// newFakeIDRAC returns an idrac.Fake which looks like a factory-default iDRAC.
+func newFakeIDRAC() *idrac.Fake {
+	values := map[string]string{
+		"iDRAC.Info.Name":                       "iDRAC",
+		"BIOS.SysProfileSettings.SysProfile":    "PerfOptimized",
+		"BIOS.ProcSettings.LogicalProc":         "Enabled",
+		"BIOS.SysSecurity.TpmInfo":              "Type: 2.0 NTC",
+		"BIOS.SysSecurity.TpmSecurity":          "Off",
+		"System.ServerPwr.PSRapidOn":            "Enabled",
+		"System.ThermalSettings.FanSpeedOffset": "Off",
+		"iDRAC.SNMP.AgentEnable":                "Disabled",
+		"iDRAC.NIC.Selection":                   "LOM1",
+		"iDRAC.IPv4.DHCPEnable":                 "Enabled",
+		"iDRAC.IPv4.Address":                    "192.168.0.120",
+		"iDRAC.IPv4.Netmask":                    "255.255.255.0",
+		"iDRAC.IPv4.Gateway":                    "192.168.0.1",
+		"iDRAC.NIC.DNSRacName":                  "idrac",
+		"iDRAC.IPMILan.PrivLimit":               "4",
+		"iDRAC.IPMILan.Enable":                  "Disabled",
+		"iDRAC.VirtualConsole.PluginType":       "0",
+	}
+	for _, idx := range []string{"2", "3", "4"} {
+		prefix := "iDRAC.Users." + idx + "."
+		values[prefix+"Username"] = ""
+		values[prefix+"SHA256Password"] = ""
+		values[prefix+"SHA256PasswordSalt"] = ""
+		values[prefix+"Privilege"] = "0x0"
+		values[prefix+"IpmiLanPrivilege"] = "15"
+		values[prefix+"IpmiSerialPrivilege"] = "15"
+		values[prefix+"Enable"] = "Disabled"
+	}
+
+	f := idrac.NewFake(values)
+	f.SetAlias("System.ThermalSettings.FanSpeedOffset", "0", "Low")
+	f.SetAlias("iDRAC.IPMILan.Enable", "1", "Enabled")
+	return f
+}
+
+func newTestConfigurator(f *idrac.Fake, dryRun bool) *dellConfigurator {
+	password := config.BMCPassword{Hash: "0123", Salt: "4567"}
+	return &dellConfigurator{
+		idrac: f,
+		desired: &idrac.Desired{
+			Profile: config.DefaultProfile(),
+			AddressConfig: &config.AddressConfig{
+				IPv4: config.IPv4Config{
+					Address: "10.0.0.1",
+					Netmask: "255.255.255.0",
+					Gateway: "10.0.0.254",
+				},
+			},
+			UserConfig: &config.UserConfig{
+				Root:    config.Credentials{Password: config.BMCPassword{Raw: "secret"}},
+				Power:   config.Credentials{Password: password},
+				Support: config.Credentials{Password: password},
+			},
+			Model:    "PowerEdge R640",
+			Hostname: "boot-0",
+		},
+		dryRun: dryRun,
+		plan:   new(plan),
+	}
+}
+
+func TestDellConfiguratorRun(t *testing.T) {
 	t.Parallel()
 
-	val, err := parseRacadmGetOutput(`[Key=iDRAC.Embedded.1#SNMP.1]
-	AgentEnable=Enabled
-	
-	`, "iDRAC.SNMP.AgentEnable")
-	if err != nil {
+	f := newFakeIDRAC()
+	dc := newTestConfigurator(f, false)
+	if err := dc.Run(context.Background()); err != nil {
 		t.Fatal(err)
 	}
-	if val != "Enabled" {
-		t.Error("unexpected value:", val)
+
+	expected := map[string]string{
+		"BIOS.SysProfileSettings.SysProfile":    "PerfPerWattOptimizedOs",
+		"BIOS.ProcSettings.LogicalProc":         "Disabled",
+		"BIOS.SysSecurity.TpmSecurity":          "On",
+		"System.ServerPwr.PSRapidOn":            "Disabled",
+		"System.ThermalSettings.FanSpeedOffset": "Low",
+		"iDRAC.SNMP.AgentEnable":                "Enabled",
+		"iDRAC.NIC.Selection":                   "Dedicated",
+		"iDRAC.IPv4.DHCPEnable":                 "Disabled",
+		"iDRAC.IPv4.Address":                    "10.0.0.1",
+		"iDRAC.IPv4.Netmask":                    "255.255.255.0",
+		"iDRAC.IPv4.Gateway":                    "10.0.0.254",
+		"iDRAC.NIC.DNSRacName":                  "boot-0-idrac",
+		"iDRAC.IPMILan.PrivLimit":               "3",
+		"iDRAC.IPMILan.Enable":                  "Enabled",
+		"iDRAC.VirtualConsole.PluginType":       "2",
+		"iDRAC.Users.2.Username":                "root",
+		"iDRAC.Users.2.Password":                "secret",
+		"iDRAC.Users.2.Privilege":               "0x1ff",
+		"iDRAC.Users.2.IpmiLanPrivilege":        "4",
+		"iDRAC.Users.3.Username":                "support",
+		"iDRAC.Users.3.SHA256Password":          "0123",
+		"iDRAC.Users.3.SHA256PasswordSalt":      "4567",
+		"iDRAC.Users.3.Privilege":               "0x11",
+		"iDRAC.Users.3.IpmiSerialPrivilege":     "15",
+		"iDRAC.Users.4.Username":                "power",
+		"iDRAC.Users.4.IpmiLanPrivilege":        "3",
+		"iDRAC.Users.4.Enable":                  "Enabled",
+	}
+	for key, value := range expected {
+		if v := f.Value(key); v != value {
+			t.Errorf("%s: expected %q, actual %q", key, value, v)
+		}
 	}
 
-	val, err = parseRacadmGetOutput(`Enabled
-	
-	`, "System.ServerPwr.PSRapidOn")
+	// The address is set right after DHCP is disabled, and users are configured last.
+	network := []string{
+		"iDRAC.NIC.Selection=Dedicated",
+		"iDRAC.IPv4.DHCPEnable=Disabled",
+		"iDRAC.IPv4.Address=10.0.0.1",
+		"iDRAC.IPv4.Gateway=10.0.0.254",
+		"iDRAC.NIC.DNSRacName=boot-0-idrac",
+		"iDRAC.IPMILan.PrivLimit=3",
+	}
+	sets := f.Sets()
+	if len(sets) < 12 || !cmp.Equal(sets[6:12], network) {
+		t.Fatal("unexpected order of settings:", sets)
+	}
+	if sets[len(sets)-1] != "iDRAC.Users.4.Enable=Enabled" {
+		t.Error("users should be configured last:", sets)
+	}
+
+	if !cmp.Equal(f.Jobs(), []string{"BIOS.Setup.1-1"}) {
+		t.Error("unexpected jobs:", f.Jobs())
+	}
+	if !dc.plan.RebootRequired {
+		t.Error("reboot should be required")
+	}
+
+	// The second run after reboot changes nothing but the raw password, which cannot be read.
+	f.CompleteJobs()
+	before := len(f.Sets())
+	dc = newTestConfigurator(f, false)
+	if err := dc.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sets := f.Sets()[before:]; !cmp.Equal(sets, []string{"iDRAC.Users.2.Password=secret"}) {
+		t.Error("unexpected settings in the second run:", sets)
+	}
+	if len(f.Jobs()) != 1 {
+		t.Error("unexpected jobs in the second run:", f.Jobs())
+	}
+}
+
+func TestDellConfiguratorDryRun(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeIDRAC()
+	f.ScheduleJob("BIOS.Setup.1-1")
+	dc := newTestConfigurator(f, true)
+	if err := dc.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.Sets()) != 0 {
+		t.Error("dry-run should not change anything:", f.Sets())
+	}
+	if len(f.Jobs()) != 1 {
+		t.Error("dry-run should not create jobs:", f.Jobs())
+	}
+	if !dc.plan.ScheduledJobs {
+		t.Error("scheduled jobs should be reported")
+	}
+	if !dc.plan.RebootRequired {
+		t.Error("reboot should be required")
+	}
+}
+
+func TestDellConfiguratorScheduledJobs(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeIDRAC()
+	f.ScheduleJob("BIOS.Setup.1-1")
+	dc := newTestConfigurator(f, false)
+	if err := dc.Run(context.Background()); err == nil {
+		t.Error("Run should fail if scheduled jobs exist")
+	}
+	if len(f.Sets()) != 0 {
+		t.Error("nothing should be changed:", f.Sets())
+	}
+}
+
+func TestDellConfiguratorConditionOrder(t *testing.T) {
+	t.Parallel()
+
+	// The condition of the second setting refers to the key changed by the first one.
+	profile, err := config.ParseProfile([]byte(`
+version: 1
+settings:
+  - key: iDRAC.SNMP.AgentEnable
+    value: Enabled
+  - key: iDRAC.VirtualConsole.PluginType
+    value: "2"
+    when:
+      key: iDRAC.SNMP.AgentEnable
+      equals: Enabled
+`))
 	if err != nil {
 		t.Fatal(err)
 	}
-	if val != "Enabled" {
-		t.Error("unexpected value:", val)
+
+	f := newFakeIDRAC()
+	dc := newTestConfigurator(f, false)
+	dc.desired.Profile = profile
+	if err := dc.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if v := f.Value("iDRAC.VirtualConsole.PluginType"); v != "2" {
+		t.Error("condition should be evaluated after the preceding setting is applied:", v)
 	}
 }
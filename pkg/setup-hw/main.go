@@ -2,10 +2,13 @@ package main
 
 import (
 	"errors"
+	"flag"
+	"fmt"
 	"os"
 
 	"github.com/cybozu-go/log"
 	"github.com/cybozu-go/setup-hw/config"
+	"github.com/cybozu-go/setup-hw/idrac"
 	"github.com/cybozu-go/setup-hw/lib"
 	"github.com/cybozu-go/well"
 )
@@ -13,22 +16,64 @@ import (
 const (
 	// ExitReboot is the status code to tell the caller to reboot.
 	ExitReboot = 10
+
+	// ExitDrift is the status code of "setup-hw verify" to tell settings differ from the desired ones.
+	ExitDrift = 2
+)
+
+var (
+	flagDryRun     = flag.Bool("dry-run", false, "show the plan of changes without applying them")
+	flagPlanFormat = flag.String("plan-format", "text", "output format of the plan and the verification results: text or json")
+	flagProfile    = flag.String("profile", config.ProfileFile, "hardware profile; the built-in profile is used if it does not exist")
+
+	flagBackend         = flag.String("backend", backendRacadm, "how to configure iDRAC: racadm or redfish")
+	flagRedfishEndpoint = flag.String("redfish-endpoint", defaultRedfishEndpoint, "endpoint of iDRAC Redfish API for --backend=redfish")
 )
 
 func main() {
+	flag.Parse()
 	well.LogConfig{}.Apply()
 
+	verify := false
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		if flag.Arg(0) != "verify" {
+			log.ErrorExit(fmt.Errorf("unknown subcommand: %s", flag.Arg(0)))
+		}
+		verify = true
+	default:
+		log.ErrorExit(errors.New("too many arguments"))
+	}
+
+	if *flagPlanFormat != "text" && *flagPlanFormat != "json" {
+		log.ErrorExit(fmt.Errorf("unknown plan format: %s", *flagPlanFormat))
+	}
+	if *flagBackend != backendRacadm && *flagBackend != backendRedfish {
+		log.ErrorExit(fmt.Errorf("unknown backend: %s", *flagBackend))
+	}
+
 	ac, uc, err := config.LoadConfig()
 	if err != nil {
 		log.ErrorExit(err)
 	}
 
+	profile, err := config.LoadProfile(*flagProfile)
+	if err != nil {
+		log.ErrorExit(err)
+	}
+
 	vendor, err := lib.DetectVendor()
 	if err != nil {
 		log.ErrorExit(err)
 	}
 
-	var setup func(*config.AddressConfig, *config.UserConfig) (bool, error)
+	if verify {
+		runVerify(vendor, ac, uc, profile)
+		return
+	}
+
+	var setup func(*config.AddressConfig, *config.UserConfig, *config.Profile, bool) (*plan, error)
 	switch vendor {
 	case lib.QEMU:
 		setup = setupQEMU
@@ -38,13 +83,48 @@ func main() {
 		log.ErrorExit(errors.New("unsupported vendor hardware"))
 	}
 
-	reboot, err := setup(ac, uc)
+	p, err := setup(ac, uc, profile, *flagDryRun)
 	if err != nil {
 		log.ErrorExit(err)
 	}
 
-	if reboot {
+	if *flagDryRun {
+		if err := p.write(os.Stdout, *flagPlanFormat); err != nil {
+			log.ErrorExit(err)
+		}
+		return
+	}
+
+	if p.RebootRequired {
 		log.Warn("reboot the server now", nil)
 		os.Exit(ExitReboot)
 	}
 }
+
+// runVerify verifies the settings and exits with ExitDrift if they differ from the desired ones.
+func runVerify(vendor lib.Vendor, ac *config.AddressConfig, uc *config.UserConfig, profile *config.Profile) {
+	var verify func(*config.AddressConfig, *config.UserConfig, *config.Profile) ([]*idrac.Result, error)
+	switch vendor {
+	case lib.QEMU:
+		verify = verifyQEMU
+	case lib.Dell:
+		verify = verifyDell
+	default:
+		log.ErrorExit(errors.New("unsupported vendor hardware"))
+	}
+
+	results, err := verify(ac, uc, profile)
+	if err != nil {
+		log.ErrorExit(err)
+	}
+	if err := writeResults(os.Stdout, results, *flagPlanFormat); err != nil {
+		log.ErrorExit(err)
+	}
+
+	if drifts := countDrifts(results); drifts != 0 {
+		log.Warn("settings differ from the desired ones", map[string]interface{}{
+			"drifts": drifts,
+		})
+		os.Exit(ExitDrift)
+	}
+}
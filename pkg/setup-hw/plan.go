@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const hiddenValue = "(hidden)"
+
+// plan is the list of settings checked in a run, and whether they are changed.
+type plan struct {
+	mu sync.Mutex
+
+	Items []*planItem `json:"items"`
+
+	// ScheduledJobs is true if jobs are already scheduled in the job queue.
+	// setup-hw does not change anything in that case.
+	ScheduledJobs bool `json:"scheduledJobs"`
+
+	// RebootRequired is true if the changes take effect after reboot.
+	RebootRequired bool `json:"rebootRequired"`
+}
+
+// planItem is a setting checked in a run.
+type planItem struct {
+	Key     string `json:"key"`
+	Current string `json:"current"`
+	Desired string `json:"desired"`
+	Changed bool   `json:"changed"`
+
+	// Reboot is true if the change is applied by a job at the next reboot.
+	Reboot bool `json:"reboot,omitempty"`
+}
+
+// isSecretKey returns whether the values of key must not be shown.
+func isSecretKey(key string) bool {
+	return strings.Contains(key, "Password")
+}
+
+// add records a setting.  Values of secret keys are hidden.
+// Methods of a nil plan do nothing.
+func (p *plan) add(key, current, desired string, changed bool) {
+	if p == nil {
+		return
+	}
+	if isSecretKey(key) {
+		current = hiddenValue
+		desired = hiddenValue
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Items = append(p.Items, &planItem{
+		Key:     key,
+		Current: current,
+		Desired: desired,
+		Changed: changed,
+	})
+}
+
+// reboot marks the change of key to be applied at the next reboot.
+func (p *plan) reboot(key string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, item := range p.Items {
+		if item.Key == key && item.Changed {
+			item.Reboot = true
+		}
+	}
+	p.RebootRequired = true
+}
+
+func (p *plan) write(w io.Writer, format string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch format {
+	case "json":
+		out, err := json.MarshalIndent(p, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(out, '\n'))
+		return err
+	case "text":
+	default:
+		return fmt.Errorf("unknown plan format: %s", format)
+	}
+
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	changes := 0
+	for _, item := range p.Items {
+		if !item.Changed {
+			printf("  %s: %s\n", item.Key, item.Current)
+			continue
+		}
+		changes++
+		suffix := ""
+		if item.Reboot {
+			suffix = " (after reboot)"
+		}
+		printf("~ %s: %s -> %s%s\n", item.Key, item.Current, item.Desired, suffix)
+	}
+
+	printf("Changes: %d\n", changes)
+	if p.ScheduledJobs {
+		printf("Scheduled jobs exist; setup-hw would fail without changing anything.\n")
+	}
+	if p.RebootRequired {
+		printf("Reboot required: yes\n")
+	} else {
+		printf("Reboot required: no\n")
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	p := new(plan)
+	p.add("BIOS.ProcSettings.LogicalProc", "Enabled", "Disabled", true)
+	p.add("iDRAC.SNMP.AgentEnable", "Enabled", "Enabled", false)
+	p.add("iDRAC.Users.3.SHA256Password", "0123", "4567", true)
+	p.reboot("BIOS.ProcSettings.LogicalProc")
+
+	buf := new(bytes.Buffer)
+	if err := p.write(buf, "text"); err != nil {
+		t.Fatal(err)
+	}
+	expected := `~ BIOS.ProcSettings.LogicalProc: Enabled -> Disabled (after reboot)
+  iDRAC.SNMP.AgentEnable: Enabled
+~ iDRAC.Users.3.SHA256Password: (hidden) -> (hidden)
+Changes: 2
+Reboot required: yes
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected text plan:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := p.write(buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Items []struct {
+			Key     string
+			Changed bool
+			Reboot  bool
+		}
+		RebootRequired bool
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Items) != 3 || !decoded.Items[0].Reboot || decoded.Items[1].Changed || !decoded.RebootRequired {
+		t.Errorf("unexpected JSON plan:\n%s", buf.String())
+	}
+
+	if err := p.write(buf, "yaml"); err == nil {
+		t.Error("unknown format was accepted")
+	}
+}
@@ -11,17 +11,23 @@ const virtualBMCPort = "/dev/virtio-ports/placemat"
 
 // setupQEMU configures virtual BMC provided by placemat.
 // https://github.com/cybozu-go/placemat/blob/master/docs/virtual_bmc.md
-func setupQEMU(ac *config.AddressConfig, uc *config.UserConfig) (bool, error) {
+// Virtual BMC has no settings to be checked, so the plan is always empty.
+func setupQEMU(ac *config.AddressConfig, uc *config.UserConfig, profile *config.Profile, dryRun bool) (*plan, error) {
+	p := new(plan)
+	if dryRun {
+		return p, nil
+	}
+
 	f, err := os.OpenFile(virtualBMCPort, os.O_WRONLY, 0644)
 	if err == nil {
 		_, err = f.WriteString(ac.IPv4.Address + "\n")
 		f.Close()
-		return false, err
+		return p, err
 	}
 
 	if os.IsNotExist(err) {
 		log.Warn("virtual BMC is not found", nil)
-		return false, nil
+		return p, nil
 	}
-	return false, err
+	return p, err
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/cybozu-go/setup-hw/config"
+	"github.com/cybozu-go/setup-hw/idrac"
+	"github.com/cybozu-go/well"
+)
+
+// verifyDell compares the current settings of BIOS and iDRAC with the desired ones without changing anything.
+func verifyDell(ac *config.AddressConfig, uc *config.UserConfig, profile *config.Profile) ([]*idrac.Result, error) {
+	r, err := newIDRAC(uc)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := newDesired(ac, uc, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*idrac.Result
+	well.Go(func(ctx context.Context) error {
+		res, err := desired.Verify(ctx, r)
+		results = res
+		return err
+	})
+	well.Stop()
+	err = well.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// verifyQEMU returns no results because virtual BMC has no settings to be checked.
+func verifyQEMU(ac *config.AddressConfig, uc *config.UserConfig, profile *config.Profile) ([]*idrac.Result, error) {
+	return nil, nil
+}
+
+// countDrifts returns the number of settings which differ from the desired ones.
+func countDrifts(results []*idrac.Result) int {
+	drifts := 0
+	for _, r := range results {
+		if !r.Compliant {
+			drifts++
+		}
+	}
+	return drifts
+}
+
+// writeResults writes the results of verification in format.  Values of secret keys are hidden.
+func writeResults(w io.Writer, results []*idrac.Result, format string) error {
+	shown := make([]*idrac.Result, len(results))
+	for i, r := range results {
+		res := *r
+		if isSecretKey(res.Key) {
+			res.Current = hiddenValue
+			res.Desired = hiddenValue
+		}
+		shown[i] = &res
+	}
+
+	switch format {
+	case "json":
+		out, err := json.MarshalIndent(struct {
+			Results []*idrac.Result `json:"results"`
+			Drifts  int             `json:"drifts"`
+		}{shown, countDrifts(results)}, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(out, '\n'))
+		return err
+	case "text":
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+	for _, r := range shown {
+		if r.Compliant {
+			printf("  %s: %s\n", r.Key, r.Current)
+			continue
+		}
+		printf("! %s: %s (desired: %s)\n", r.Key, r.Current, r.Desired)
+	}
+	printf("Drifts: %d\n", countDrifts(results))
+	return err
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cybozu-go/setup-hw/idrac"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeIDRAC()
+	dc := newTestConfigurator(f, false)
+	if err := dc.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	f.CompleteJobs()
+	before := len(f.Sets())
+
+	results, err := dc.desired.Verify(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countDrifts(results); n != 0 {
+		t.Error("no drifts are expected after setup:", n)
+	}
+
+	// Someone enables hyper-threading.
+	if err := f.Set(context.Background(), "BIOS.ProcSettings.LogicalProc", "Enabled"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set(context.Background(), "iDRAC.Users.3.SHA256Password", "89ab"); err != nil {
+		t.Fatal(err)
+	}
+	results, err = dc.desired.Verify(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countDrifts(results); n != 2 {
+		t.Error("unexpected number of drifts:", n)
+	}
+	if len(f.Sets()) != before+2 {
+		t.Error("verification should not change anything:", f.Sets()[before:])
+	}
+
+	for _, r := range results {
+		if r.Key == "iDRAC.Users.2.Password" {
+			t.Error("raw password cannot be verified")
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	err = writeResults(buf, []*idrac.Result{
+		{Key: "BIOS.ProcSettings.LogicalProc", Current: "Enabled", Desired: "Disabled"},
+		{Key: "iDRAC.SNMP.AgentEnable", Current: "Enabled", Desired: "Enabled", Compliant: true},
+		{Key: "iDRAC.Users.3.SHA256Password", Current: "89ab", Desired: "0123"},
+	}, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `! BIOS.ProcSettings.LogicalProc: Enabled (desired: Disabled)
+  iDRAC.SNMP.AgentEnable: Enabled
+! iDRAC.Users.3.SHA256Password: (hidden) (desired: (hidden))
+Drifts: 2
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected text results:\n%s", buf.String())
+	}
+}
@@ -2,45 +2,43 @@ package main
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"os/exec"
 	"time"
 
-	"github.com/cybozu-go/well"
+	"github.com/cybozu-go/setup-hw/idrac"
 )
 
-const idracadm7Path = "/opt/dell/srvadmin/bin/idracadm7"
+// reconnectWait is the time to wait before connecting a remote image.
+// if `idracadm7 remoteimage -c` is executed immediately after disconnecting, it will fail.
+var reconnectWait = time.Second * 5
 
 func setupDell(ctx context.Context, url string) error {
-	err := well.CommandContext(ctx, idracadm7Path, "vmdisconnect").Run()
-	// we cannot use exit status to detect errors because `idracadm7 vmdisconnect` returns nonzero status if it is not connected.
-	var exitError *exec.ExitError
-	if err != nil && !errors.As(err, &exitError) {
-		return fmt.Errorf("racadm vmdisconnect failed: %w", err)
-	}
+	// Run each idracadm7 command only once so that failures are reported immediately.
+	return bootDell(ctx, idrac.NewSingleShotRacadm(), url)
+}
 
-	// `idracadm7 remoteimage -d` returns zero if the remote image is not connected.
-	err = well.CommandContext(ctx, idracadm7Path, "remoteimage", "-d").Run()
-	if err != nil {
-		return fmt.Errorf("racadm remoteimage -d failed: %w", err)
+func bootDell(ctx context.Context, r idrac.IDRAC, url string) error {
+	if err := r.VMDisconnect(ctx); err != nil {
+		return err
+	}
+	if err := r.RemoteImageDisconnect(ctx); err != nil {
+		return err
 	}
 
-	// if `idracadm7 remoteimage -c` is executed immediately after disconnecting, it will fail.
-	time.Sleep(time.Second * 5)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(reconnectWait):
+	}
 
-	err = well.CommandContext(ctx, idracadm7Path, "remoteimage", "-c", "-l", url).Run()
-	if err != nil {
-		return fmt.Errorf("racadm remoteimage -c failed: %w", err)
+	if err := r.RemoteImageConnect(ctx, url); err != nil {
+		return err
 	}
 
-	err = well.CommandContext(ctx, idracadm7Path, "set", "iDRAC.VirtualMedia.BootOnce", "1").Run()
-	if err != nil {
+	if err := r.Set(ctx, "iDRAC.VirtualMedia.BootOnce", "1"); err != nil {
 		return fmt.Errorf("racadm set BootOnce failed: %w", err)
 	}
-
-	err = well.CommandContext(ctx, idracadm7Path, "set", "iDRAC.ServerBoot.FirstBootDevice", "VCD-DVD").Run()
-	if err != nil {
+	if err := r.Set(ctx, "iDRAC.ServerBoot.FirstBootDevice", "VCD-DVD"); err != nil {
 		return fmt.Errorf("racadm set FirstBootDevice failed: %w", err)
 	}
 
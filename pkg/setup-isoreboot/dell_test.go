@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cybozu-go/setup-hw/idrac"
+)
+
+func TestBootDell(t *testing.T) {
+	reconnectWait = 0
+
+	url := "http://10.0.0.1/ubuntu.iso"
+	f := idrac.NewFake(nil)
+	if err := f.RemoteImageConnect(context.Background(), "http://10.0.0.1/old.iso"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bootDell(context.Background(), f, url); err != nil {
+		t.Fatal(err)
+	}
+	if f.RemoteImage() != url {
+		t.Error("unexpected remote image:", f.RemoteImage())
+	}
+	if v := f.Value("iDRAC.VirtualMedia.BootOnce"); v != "1" {
+		t.Error("unexpected BootOnce:", v)
+	}
+	if v := f.Value("iDRAC.ServerBoot.FirstBootDevice"); v != "VCD-DVD" {
+		t.Error("unexpected FirstBootDevice:", v)
+	}
+
+	f = idrac.NewFake(nil)
+	f.SetError("Set:iDRAC.ServerBoot.FirstBootDevice", errors.New("exit status 1"))
+	err := bootDell(context.Background(), f, url)
+	if err == nil || err.Error() != "racadm set FirstBootDevice failed: exit status 1" {
+		t.Error("unexpected error:", err)
+	}
+}
@@ -2,6 +2,7 @@ package redfish
 
 import (
 	"context"
+	"strconv"
 	"sync/atomic"
 	"time"
 
@@ -28,8 +29,23 @@ func NewCollected(data map[string]*gabs.Container, rule *CollectRule) *Collected
 
 // Collected represents the collected data from Redfish
 type Collected struct {
-	data map[string]*gabs.Container
-	rule *CollectRule
+	data  map[string]*gabs.Container
+	rule  *CollectRule
+	stats TraverseStats
+}
+
+// TraverseStats represents statistics of a traversal.
+type TraverseStats struct {
+	// Fetched is the number of pages fetched and parsed successfully.
+	Fetched int
+	// Failed is the number of pages failed to be fetched.
+	Failed int
+	// Excluded is the number of pages skipped by the exclude rules.
+	Excluded int
+	// ParseErrors is the number of pages fetched but failed to be parsed.
+	ParseErrors int
+	// StatusCodes counts responses by HTTP status code, including retried ones.
+	StatusCodes map[int]int
 }
 
 // Data returns the collected data
@@ -42,44 +58,119 @@ func (c Collected) Rule() *CollectRule {
 	return c.rule
 }
 
+// Stats returns the statistics of the traversal.
+func (c Collected) Stats() TraverseStats {
+	return c.stats
+}
+
 // Collector implements prometheus.Collector interface.
 type Collector struct {
 	ruleGetter                    RuleGetter
 	client                        Client
-	collected                     atomic.Value
-	lastUpdate                    time.Time
+	snapshot                      atomic.Value // *snapshot
 	lastUpdateDesc                *prometheus.Desc
 	lastUpdateDurationMinutesDesc *prometheus.Desc
+	upDesc                        *prometheus.Desc
+	pagesDesc                     *prometheus.Desc
+	traverseDuration              prometheus.Histogram
+	httpResponses                 *prometheus.CounterVec
+}
+
+// snapshot is the result of an update.
+// Update publishes a new snapshot as a whole, so that Collect never sees
+// the rule, the data, and the timestamp from different updates.
+// A snapshot must not be modified once published.
+type snapshot struct {
+	collected  Collected
+	lastUpdate time.Time
+	up         bool
 }
 
 // NewCollector returns a new instance of Collector.
 func NewCollector(ruleGetter RuleGetter, client Client) (*Collector, error) {
 	desc1 := prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "last_update"), "", nil, nil)
 	desc2 := prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "last_update_duration_minutes"), "", nil, nil)
-	return &Collector{ruleGetter: ruleGetter, client: client, lastUpdateDesc: desc1, lastUpdateDurationMinutesDesc: desc2}, nil
+	upDesc := prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "up"),
+		"1 if the last traversal of Redfish data succeeded, 0 otherwise.", nil, nil)
+	pagesDesc := prometheus.NewDesc(prometheus.BuildFQName(namespace, "traverse", "pages"),
+		"Number of Redfish pages in the last traversal by result.", []string{"result"}, nil)
+	traverseDuration := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "traverse",
+		Name:      "duration_seconds",
+		Help:      "Duration of traversals of Redfish data.",
+		Buckets:   []float64{5, 10, 30, 60, 120, 300, 600},
+	})
+	httpResponses := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "traverse",
+		Name:      "http_responses_total",
+		Help:      "Number of HTTP responses from Redfish API by status code.",
+	}, []string{"code"})
+	c := &Collector{
+		ruleGetter:                    ruleGetter,
+		client:                        client,
+		lastUpdateDesc:                desc1,
+		lastUpdateDurationMinutesDesc: desc2,
+		upDesc:                        upDesc,
+		pagesDesc:                     pagesDesc,
+		traverseDuration:              traverseDuration,
+		httpResponses:                 httpResponses,
+	}
+	c.snapshot.Store(&snapshot{})
+	return c, nil
+}
+
+func (c *Collector) load() *snapshot {
+	return c.snapshot.Load().(*snapshot)
 }
 
 // Describe sends descriptions of metrics.
-func (c Collector) Describe(ch chan<- *prometheus.Desc) {
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.lastUpdateDesc
 	ch <- c.lastUpdateDurationMinutesDesc
+	ch <- c.upDesc
+	ch <- c.pagesDesc
+	c.traverseDuration.Describe(ch)
+	c.httpResponses.Describe(ch)
 }
 
 // Collect sends metrics Collected from BMC via Redfish.
-func (c Collector) Collect(ch chan<- prometheus.Metric) {
-	m := prometheus.MustNewConstMetric(c.lastUpdateDesc, prometheus.CounterValue, float64(c.lastUpdate.Unix()))
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	snap := c.load()
+
+	m := prometheus.MustNewConstMetric(c.lastUpdateDesc, prometheus.CounterValue, float64(snap.lastUpdate.Unix()))
 	ch <- m
-	m = prometheus.MustNewConstMetric(c.lastUpdateDurationMinutesDesc, prometheus.GaugeValue, time.Since(c.lastUpdate).Minutes())
+	m = prometheus.MustNewConstMetric(c.lastUpdateDurationMinutesDesc, prometheus.GaugeValue, time.Since(snap.lastUpdate).Minutes())
 	ch <- m
+	var up float64
+	if snap.up {
+		up = 1
+	}
+	ch <- prometheus.MustNewConstMetric(c.upDesc, prometheus.GaugeValue, up)
+	c.traverseDuration.Collect(ch)
+	c.httpResponses.Collect(ch)
 
-	v := c.collected.Load()
-	if v == nil {
+	cl := snap.collected
+	if cl.rule == nil {
 		return
 	}
-	cl := v.(Collected)
+
+	stats := cl.stats
+	for _, p := range []struct {
+		result string
+		count  int
+	}{
+		{"fetched", stats.Fetched},
+		{"failed", stats.Failed},
+		{"excluded", stats.Excluded},
+		{"parse_error", stats.ParseErrors},
+	} {
+		ch <- prometheus.MustNewConstMetric(c.pagesDesc, prometheus.GaugeValue, float64(p.count), p.result)
+	}
 
 	for _, rule := range cl.rule.MetricRules {
-		metrics := rule.matchDataMap(cl)
+		metrics := rule.matchDataMap(cl, nil)
 		for _, m := range metrics {
 			ch <- m
 		}
@@ -94,13 +185,36 @@ func (c *Collector) Update(ctx context.Context) {
 		log.Error("failed to get rule", map[string]interface{}{
 			log.FnError: err,
 		})
+		// Keep the previous data, but report the failure.
+		prev := c.load()
+		c.snapshot.Store(&snapshot{
+			collected:  prev.collected,
+			lastUpdate: prev.lastUpdate,
+			up:         false,
+		})
 		return
 	}
 	log.Info("start update", nil)
 	ctx1, cancel := context.WithTimeout(ctx, 10*time.Minute)
 	defer cancel()
+	start := time.Now()
 	cl := c.client.Traverse(ctx1, rule)
-	c.collected.Store(cl)
-	c.lastUpdate = time.Now()
-	log.Info("finish update", nil)
+	c.traverseDuration.Observe(time.Since(start).Seconds())
+	for code, count := range cl.stats.StatusCodes {
+		c.httpResponses.WithLabelValues(strconv.Itoa(code)).Add(float64(count))
+	}
+
+	// The traversal is regarded as failed if no page could be fetched.
+	// Every page is reached from the root page, so this is the case when the root page could not be fetched.
+	c.snapshot.Store(&snapshot{
+		collected:  cl,
+		lastUpdate: time.Now(),
+		up:         cl.stats.Fetched > 0,
+	})
+	log.Info("finish update", map[string]interface{}{
+		"fetched":      cl.stats.Fetched,
+		"failed":       cl.stats.Failed,
+		"excluded":     cl.stats.Excluded,
+		"parse_errors": cl.stats.ParseErrors,
+	})
 }
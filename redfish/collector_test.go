@@ -66,6 +66,26 @@ func testDescribe(t *testing.T) {
 			name:       "hw_last_update_duration_minutes",
 			labelNames: []string{},
 		},
+		{
+			name:       "hw_up",
+			help:       "1 if the last traversal of Redfish data succeeded, 0 otherwise.",
+			labelNames: []string{},
+		},
+		{
+			name:       "hw_traverse_pages",
+			help:       "Number of Redfish pages in the last traversal by result.",
+			labelNames: []string{"result"},
+		},
+		{
+			name:       "hw_traverse_duration_seconds",
+			help:       "Duration of traversals of Redfish data.",
+			labelNames: []string{},
+		},
+		{
+			name:       "hw_traverse_http_responses_total",
+			help:       "Number of HTTP responses from Redfish API by status code.",
+			labelNames: []string{"code"},
+		},
 	}
 
 	cc, err := clientConfig()
@@ -134,13 +154,7 @@ func testCollect(t *testing.T) {
 		},
 	}
 
-	expectedSet := []*struct {
-		matched bool
-		name    string
-		typ     prommodel.MetricType
-		value   float64
-		labels  map[string]string
-	}{
+	expectedSet := []*expected{
 		{
 			name:  "hw_chassis_status_health",
 			typ:   prommodel.MetricType_GAUGE,
@@ -167,6 +181,71 @@ func testCollect(t *testing.T) {
 				"sub":     "2",
 			},
 		},
+		{
+			name:  "hw_chassis_sub_status_state",
+			typ:   prommodel.MetricType_GAUGE,
+			value: 0, // Enabled
+			labels: map[string]string{
+				"chassis": "System.Embedded.1",
+				"sub":     "Sub0", // Name of the element
+			},
+		},
+		{
+			name:  "hw_chassis_sub_status_state",
+			typ:   prommodel.MetricType_GAUGE,
+			value: 0, // Enabled
+			labels: map[string]string{
+				"chassis": "System.Embedded.1",
+				"sub":     "1", // index as Name is absent
+			},
+		},
+		{
+			name:  "hw_chassis_sub_status_state",
+			typ:   prommodel.MetricType_GAUGE,
+			value: 0, // Enabled
+			labels: map[string]string{
+				"chassis": "System.Embedded.1",
+				"sub":     "2", // index as Name is absent
+			},
+		},
+		{
+			name:  "hw_chassis_power_state",
+			typ:   prommodel.MetricType_GAUGE,
+			value: 1, // Off
+			labels: map[string]string{
+				"chassis": "System.Embedded.1",
+			},
+		},
+		{
+			name:  "hw_chassis_indicator_led",
+			typ:   prommodel.MetricType_GAUGE,
+			value: -1, // Blinking is not defined
+			labels: map[string]string{
+				"chassis": "System.Embedded.1",
+			},
+		},
+		{
+			name:  "hw_chassis_info",
+			typ:   prommodel.MetricType_GAUGE,
+			value: 1,
+			labels: map[string]string{
+				"chassis":      "System.Embedded.1",
+				"model":        "PowerEdge R640",
+				"manufacturer": "Dell Inc.",
+				"serialnumber": "", // absent
+			},
+		},
+		{
+			name:  "hw_chassis_sub_firmware_info",
+			typ:   prommodel.MetricType_GAUGE,
+			value: 1,
+			labels: map[string]string{
+				"chassis":         "System.Embedded.1",
+				"sub":             "0",
+				"firmwareversion": "1.2.3",
+				"name":            "Sub0",
+			},
+		},
 		{
 			name:  "hw_block_status_health",
 			typ:   prommodel.MetricType_GAUGE,
@@ -189,6 +268,7 @@ func testCollect(t *testing.T) {
 			labels: map[string]string{},
 		},
 	}
+	expectedSet = append(expectedSet, traverseMetrics()...)
 
 	cc, err := clientConfig()
 	if err != nil {
@@ -215,7 +295,7 @@ func testCollect(t *testing.T) {
 		}
 		dataMap[input.urlPath] = data
 	}
-	collector.collected.Store(Collected{data: dataMap, rule: cc.Rule})
+	collector.snapshot.Store(&snapshot{collected: Collected{data: dataMap, rule: cc.Rule}})
 
 	registry := prometheus.NewRegistry()
 	err = registry.Register(collector)
@@ -252,6 +332,8 @@ func testCollect(t *testing.T) {
 					val = actual.GetGauge().GetValue()
 				case prommodel.MetricType_COUNTER:
 					val = actual.GetCounter().GetValue()
+				case prommodel.MetricType_HISTOGRAM:
+					val = float64(actual.GetHistogram().GetSampleCount())
 				default:
 					t.Fatalf("unknown type: ")
 				}
@@ -273,6 +355,33 @@ func testCollect(t *testing.T) {
 	}
 }
 
+// traverseMetrics returns the metrics about traversal that any collector returns.
+func traverseMetrics() []*expected {
+	result := []*expected{
+		{
+			name:   "hw_up",
+			typ:    prommodel.MetricType_GAUGE,
+			value:  math.NaN(), // don't care
+			labels: map[string]string{},
+		},
+		{
+			name:   "hw_traverse_duration_seconds",
+			typ:    prommodel.MetricType_HISTOGRAM,
+			value:  math.NaN(), // don't care
+			labels: map[string]string{},
+		},
+	}
+	for _, r := range []string{"fetched", "failed", "excluded", "parse_error"} {
+		result = append(result, &expected{
+			name:   "hw_traverse_pages",
+			typ:    prommodel.MetricType_GAUGE,
+			value:  math.NaN(), // don't care
+			labels: map[string]string{"result": r},
+		})
+	}
+	return result
+}
+
 func matchLabels(actual, expected map[string]string) bool {
 	if len(actual) != len(expected) {
 		return false
@@ -352,11 +461,11 @@ func testUpdate(t *testing.T) {
 	}
 
 	collector.Update(context.Background())
-	v := collector.collected.Load()
-	if v == nil {
+	snap := collector.load()
+	if snap.collected.rule == nil {
 		t.Fatal(errors.New("Update() did not store traversed data"))
 	}
-	cl := v.(Collected)
+	cl := snap.collected
 
 	for _, input := range inputs {
 		if !input.needed {
@@ -390,10 +499,137 @@ ActualLoop:
 		}
 		t.Error("extra path was traversed:", path)
 	}
+
+	// The absent path linked from the chassis fails, and the trash is excluded.
+	stats := cl.Stats()
+	if stats.Fetched != 2 || stats.Failed != 1 || stats.Excluded != 1 || stats.ParseErrors != 0 {
+		t.Errorf("wrong traverse stats: %+v", stats)
+	}
+	if stats.StatusCodes[http.StatusOK] != 2 || stats.StatusCodes[http.StatusNotFound] != 1 {
+		t.Errorf("wrong status code counts: %v", stats.StatusCodes)
+	}
+	if !snap.up {
+		t.Error("hw_up is not 1 after successful update")
+	}
+}
+
+// generationClient returns data whose value is the number of Traverse calls so far.
+type generationClient struct {
+	generation int
+}
+
+func (c *generationClient) Traverse(ctx context.Context, rule *CollectRule) Collected {
+	c.generation++
+	data, err := gabs.Consume(map[string]interface{}{"Value": float64(c.generation)})
+	if err != nil {
+		panic(err)
+	}
+	return Collected{
+		data:  dataMap{"/redfish/v1/Test": data},
+		rule:  rule,
+		stats: TraverseStats{Fetched: c.generation},
+	}
+}
+
+func (c *generationClient) GetVersion(ctx context.Context) (string, error) {
+	return "1.0.0", nil
+}
+
+func testScrapeDuringUpdate(t *testing.T) {
+	t.Parallel()
+
+	// Odd generations use the rule for "odd", and even ones use the rule for "even".
+	rules := make([]*CollectRule, 2)
+	for i, name := range []string{"even", "odd"} {
+		rules[i] = &CollectRule{
+			TraverseRule: TraverseRule{Root: "/redfish/v1"},
+			MetricRules: []*MetricRule{
+				{
+					Path: "/redfish/v1/Test",
+					PropertyRules: []*PropertyRule{
+						{Pointer: "/Value", Name: name, Type: "number"},
+					},
+				},
+			},
+		}
+		if err := rules[i].Compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := &generationClient{}
+	calls := 0
+	collector, err := NewCollector(func(context.Context) (*CollectRule, error) {
+		calls++
+		return rules[calls%2], nil
+	}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			collector.Update(context.Background())
+		}
+	}()
+
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+
+		metricFamilies, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var fetched float64
+		values := make(map[string]float64)
+		for _, mf := range metricFamilies {
+			switch mf.GetName() {
+			case "hw_traverse_pages":
+				for _, m := range mf.GetMetric() {
+					for _, l := range m.GetLabel() {
+						if l.GetName() == "result" && l.GetValue() == "fetched" {
+							fetched = m.GetGauge().GetValue()
+						}
+					}
+				}
+			case "hw_odd", "hw_even":
+				values[mf.GetName()] = mf.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+		if len(values) != 1 {
+			t.Fatal("metrics from different rules were mixed:", values)
+		}
+		for name, v := range values {
+			expectedName := "hw_even"
+			if int(v)%2 == 1 {
+				expectedName = "hw_odd"
+			}
+			if name != expectedName || v != fetched {
+				t.Fatal("metrics from different updates were mixed; name:", name, "value:", v, "fetched:", fetched)
+			}
+		}
+	}
 }
 
 func TestCollector(t *testing.T) {
 	t.Run("Describe", testDescribe)
 	t.Run("Collect", testCollect)
 	t.Run("Update", testUpdate)
+	t.Run("ScrapeDuringUpdate", testScrapeDuringUpdate)
 }
@@ -89,3 +89,38 @@ func boolConverter(data interface{}) (float64, error) {
 	}
 	return -1, fmt.Errorf("unknown health value: %t", health)
 }
+
+// enumConverter returns a converter which maps strings to numbers according to values.
+// Other values are converted to def if it is given.
+func enumConverter(values map[string]float64, def *float64) converter {
+	return func(data interface{}) (float64, error) {
+		if str, ok := data.(string); ok {
+			if value, ok := values[str]; ok {
+				return value, nil
+			}
+		}
+		if def != nil {
+			return *def, nil
+		}
+		return -1, fmt.Errorf("unknown enum value: %v", data)
+	}
+}
+
+// InferType returns the name of the built-in type which can convert the value.
+// Strings are inferred as `health` or `state` only if they are valid values of those types.
+// It returns false if no type is suitable.
+func InferType(data interface{}) (string, bool) {
+	switch data.(type) {
+	case bool:
+		return "bool", true
+	case float64:
+		return "number", true
+	case string:
+		for _, typ := range []string{"health", "state"} {
+			if _, err := typeToConverters[typ](data); err == nil {
+				return typ, true
+			}
+		}
+	}
+	return "", false
+}
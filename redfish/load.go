@@ -0,0 +1,52 @@
+package redfish
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+)
+
+// RuleFileExt is the extension of collection rule files.
+const RuleFileExt = ".yml"
+
+// LoadRuleFile reads a collection rule from a YAML file, and validates and compiles it.
+func LoadRuleFile(filename string) (*CollectRule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := new(CollectRule)
+	if err := yaml.Unmarshal(data, rule); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if err := rule.Compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return rule, nil
+}
+
+// LoadRules reads all collection rule files in dir.
+// The rules are keyed by their file names like Rules.
+// If any of the files is invalid, LoadRules returns an error and no rules.
+func LoadRules(dir string) (map[string]*CollectRule, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*"+RuleFileExt))
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make(map[string]*CollectRule)
+	for _, filename := range filenames {
+		rule, err := LoadRuleFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		rules[filepath.Base(filename)] = rule
+	}
+	return rules, nil
+}
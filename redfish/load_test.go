@@ -0,0 +1,48 @@
+package redfish
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRules(t *testing.T) {
+	t.Parallel()
+
+	valid, err := os.ReadFile("../testdata/redfish_collect.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.yml"), valid, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ignored.yaml"), []byte("invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("unexpected number of rules: %d", len(rules))
+	}
+	rule, ok := rules["a.yml"]
+	if !ok {
+		t.Fatalf("rule is not keyed by file name: %v", rules)
+	}
+	if rule.MetricRules[0].PropertyRules[0].desc == nil {
+		t.Error("rule is not compiled")
+	}
+
+	// A rule without `Root` is invalid.
+	if err := os.WriteFile(filepath.Join(dir, "b.yml"), []byte("Traverse: {}\nMetrics: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rules, err = LoadRules(dir)
+	if err == nil {
+		t.Errorf("invalid rule was accepted: %v", rules)
+	}
+}
@@ -0,0 +1,60 @@
+package redfish
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PropertyMatch is the result of matching a property rule against collected data.
+type PropertyMatch struct {
+	// Path is the path of the metric rule.
+	Path string
+	// Pointer and Name are those of the property rule.
+	Pointer string
+	Name    string
+	// Pages is the number of pages matched by Path.
+	Pages int
+	// Metrics is the number of metrics produced by the property rule.
+	Metrics int
+	// NotFound is the number of times the pointed value was not found in the matched pages.
+	NotFound int
+	// ConversionErrors is the number of values which failed to be converted into metrics.
+	ConversionErrors int
+}
+
+// notFound counts a value not found.  It does nothing if m is nil.
+func (m *PropertyMatch) notFound() {
+	if m != nil {
+		m.NotFound++
+	}
+}
+
+// conversionError counts a value failed to be converted.  It does nothing if m is nil.
+func (m *PropertyMatch) conversionError() {
+	if m != nil {
+		m.ConversionErrors++
+	}
+}
+
+// Metrics converts the collected data into metrics by the metric rules, as Collector does.
+// It also returns how many pages and metrics each property rule matched.
+func (c Collected) Metrics() ([]prometheus.Metric, []PropertyMatch) {
+	if c.rule == nil {
+		return nil, nil
+	}
+
+	var metrics []prometheus.Metric
+	var matches []PropertyMatch
+	for _, mr := range c.rule.MetricRules {
+		first := len(matches)
+		for _, pr := range mr.PropertyRules {
+			matches = append(matches, PropertyMatch{
+				Path:    mr.Path,
+				Pointer: pr.Pointer,
+				Name:    pr.Name,
+			})
+		}
+
+		metrics = append(metrics, mr.matchDataMap(c, matches[first:])...)
+	}
+	return metrics, matches
+}
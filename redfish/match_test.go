@@ -0,0 +1,67 @@
+package redfish
+
+import (
+	"testing"
+
+	"github.com/cybozu-go/setup-hw/gabs"
+)
+
+func TestCollectedMetrics(t *testing.T) {
+	t.Parallel()
+
+	rule := &CollectRule{
+		TraverseRule: TraverseRule{Root: "/redfish/v1"},
+		MetricRules: []*MetricRule{
+			{
+				Path: "/redfish/v1/Chassis/{chassis}",
+				PropertyRules: []*PropertyRule{
+					{Pointer: "/Status/Health", Name: "chassis_status_health", Type: "health"},
+					{Pointer: "/Status/NoSuchProperty", Name: "chassis_status_none", Type: "health"},
+				},
+			},
+			{
+				Path: "/redfish/v1/Systems/{system}",
+				PropertyRules: []*PropertyRule{
+					{Pointer: "/Status/Health", Name: "system_status_health", Type: "health"},
+				},
+			},
+		},
+	}
+	if err := rule.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make(map[string]*gabs.Container)
+	for _, path := range []string{"/redfish/v1/Chassis/1", "/redfish/v1/Chassis/2"} {
+		parsed, err := gabs.ParseJSON([]byte(`{"Status": {"Health": "OK"}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[path] = parsed
+	}
+
+	parsed, err := gabs.ParseJSON([]byte(`{"Status": {"Health": "Bogus"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data["/redfish/v1/Systems/1"] = parsed
+
+	metrics, matches := NewCollected(data, rule).Metrics()
+	if len(metrics) != 2 {
+		t.Error("wrong number of metrics:", len(metrics))
+	}
+
+	expected := []PropertyMatch{
+		{Path: "/redfish/v1/Chassis/{chassis}", Pointer: "/Status/Health", Name: "chassis_status_health", Pages: 2, Metrics: 2},
+		{Path: "/redfish/v1/Chassis/{chassis}", Pointer: "/Status/NoSuchProperty", Name: "chassis_status_none", Pages: 2, Metrics: 0, NotFound: 2},
+		{Path: "/redfish/v1/Systems/{system}", Pointer: "/Status/Health", Name: "system_status_health", Pages: 1, Metrics: 0, ConversionErrors: 1},
+	}
+	if len(matches) != len(expected) {
+		t.Fatalf("wrong number of matches: %+v", matches)
+	}
+	for i := range expected {
+		if matches[i] != expected[i] {
+			t.Errorf("unexpected match; expected: %+v, actual: %+v", expected[i], matches[i])
+		}
+	}
+}
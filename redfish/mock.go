@@ -107,10 +107,11 @@ func (c *mockClient) Traverse(ctx context.Context, rule *CollectRule) Collected
 		log.Error("cannot open dummy data file: "+c.filename, map[string]interface{}{
 			log.FnError: err,
 		})
-		return Collected{data: c.defaultData, rule: rule}
+		return Collected{data: c.defaultData, rule: rule, stats: TraverseStats{Fetched: len(c.defaultData)}}
 	}
 
-	return Collected{data: makeDataMap(cBytes), rule: rule}
+	data := makeDataMap(cBytes)
+	return Collected{data: data, rule: rule, stats: TraverseStats{Fetched: len(data)}}
 }
 
 func (c *mockClient) GetVersion(ctx context.Context) (string, error) {
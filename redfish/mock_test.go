@@ -85,6 +85,8 @@ func TestMockClient(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	expectedSet = append(expectedSet, traverseMetrics()...)
+
 	client := NewMockClient("../testdata/mock_data.json")
 
 	checkResult(t, rule, client, expectedSet)
@@ -153,6 +155,8 @@ func TestMockClientDefaultData(t *testing.T) {
 		},
 	}
 
+	expectedSet = append(expectedSet, traverseMetrics()...)
+
 	client := NewMockClient("../testdata/no_exist_file")
 
 	checkResult(t, Rules["qemu.yml"], client, expectedSet)
@@ -203,6 +207,8 @@ func checkResult(t *testing.T, rule *CollectRule, client Client, expectedSet []*
 					val = actual.GetGauge().GetValue()
 				case prommodel.MetricType_COUNTER:
 					val = actual.GetCounter().GetValue()
+				case prommodel.MetricType_HISTOGRAM:
+					val = float64(actual.GetHistogram().GetSampleCount())
 				default:
 					t.Fatalf("unknown type: ")
 				}
@@ -0,0 +1,55 @@
+package redfish
+
+import (
+	"net/http"
+	"time"
+)
+
+// redactedHeaders are the response headers not to be recorded because they contain secrets.
+var redactedHeaders = []string{"X-Auth-Token", "Set-Cookie"}
+
+// Exchange is a request to Redfish API and its response.
+type Exchange struct {
+	Method string
+	URL    string
+	Start  time.Time
+
+	// Latency is the time from sending the request to reading the whole response body.
+	Latency time.Duration
+
+	// Status, Header, and Body are those of the response.
+	// Secret headers such as X-Auth-Token are removed from Header.
+	Status int
+	Header http.Header
+	Body   []byte
+
+	// Err is the error occurred in sending the request or reading the response.
+	Err error
+}
+
+// Recorder receives exchanges with Redfish API.
+// It is called concurrently during traversal.
+type Recorder func(*Exchange)
+
+func (c *redfishClient) record(req *http.Request, start time.Time, resp *http.Response, body []byte, err error) {
+	if c.recorder == nil {
+		return
+	}
+
+	ex := &Exchange{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Start:   start,
+		Latency: time.Since(start),
+		Body:    body,
+		Err:     err,
+	}
+	if resp != nil {
+		ex.Status = resp.StatusCode
+		ex.Header = resp.Header.Clone()
+		for _, h := range redactedHeaders {
+			ex.Header.Del(h)
+		}
+	}
+	c.recorder(ex)
+}
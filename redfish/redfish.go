@@ -1,13 +1,19 @@
 package redfish
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cybozu-go/log"
@@ -15,12 +21,35 @@ import (
 	"github.com/cybozu-go/setup-hw/gabs"
 )
 
+const (
+	sessionsPath = "/redfish/v1/SessionService/Sessions"
+	defaultUser  = "support"
+
+	// nextLinkKey is the property linking to the next page of a collection.
+	nextLinkKey = "Members@odata.nextLink"
+
+	// maxCollectionPages is the maximum number of pages of a collection to be merged.
+	// This stops following nextLink of a broken BMC which never ends.
+	maxCollectionPages = 1000
+)
+
 type redfishClient struct {
-	endpoint   *url.URL
-	user       string
-	password   string
-	httpClient *http.Client
-	noEscape   bool
+	endpoint       *url.URL
+	user           string
+	password       string
+	httpClient     *http.Client
+	noEscape       bool
+	parallelism    int
+	retryPolicy    *RetryPolicy
+	requestTimeout time.Duration
+	recorder       Recorder
+}
+
+// session represents a Redfish session.
+// token is sent as X-Auth-Token, and location is the URL of the session resource to be deleted.
+type session struct {
+	token    string
+	location string
 }
 
 // ClientConfig is a set of configurations for redfishClient.
@@ -30,50 +59,200 @@ type ClientConfig struct {
 	UserConfig    *config.UserConfig
 	Rule          *CollectRule
 	NoEscape      bool
+
+	// User is the name of the BMC user whose password is UserConfig.Support.
+	// If this is empty, "support" is used.
+	User string
+
+	// Parallelism is the maximum number of concurrent requests in traversal.
+	// If this is zero or negative, pages are fetched one by one.
+	Parallelism int
+
+	// RetryPolicy specifies how to retry failed GET requests.
+	// If this is nil, DefaultRetryPolicy() is used.
+	RetryPolicy *RetryPolicy
+
+	// RequestTimeout is the timeout of each request including reading its response body.
+	// If this is not positive, DefaultRequestTimeout is used.
+	RequestTimeout time.Duration
+
+	// Recorder is called for every request and its response if not nil.
+	Recorder Recorder
 }
 
 // NewRedfishClient create a client for Redfish API
 func NewRedfishClient(cc *ClientConfig) (Client, error) {
-	endpoint, err := url.Parse("https://" + cc.AddressConfig.IPv4.Address)
+	// The address may be an IPv6 address with or without brackets.
+	host := strings.TrimSuffix(strings.TrimPrefix(cc.AddressConfig.IPv4.Address, "["), "]")
+	if cc.Port != "" {
+		host = net.JoinHostPort(host, cc.Port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	endpoint, err := url.Parse("https://" + host)
 	if err != nil {
 		return nil, err
 	}
 
-	if cc.Port != "" {
-		endpoint.Host = endpoint.Host + ":" + cc.Port
+	parallelism := cc.Parallelism
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	user := cc.User
+	if user == "" {
+		user = defaultUser
+	}
+
+	retryPolicy := cc.RetryPolicy
+	if retryPolicy == nil {
+		retryPolicy = DefaultRetryPolicy()
+	}
+
+	requestTimeout := cc.RequestTimeout
+	if requestTimeout <= 0 {
+		requestTimeout = DefaultRequestTimeout
 	}
 
 	transport := &http.Transport{
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: true,
 		},
+		MaxIdleConnsPerHost: parallelism,
 	}
 
 	return &redfishClient{
 		endpoint: endpoint,
-		user:     "support",
+		user:     user,
 		password: cc.UserConfig.Support.Password.Raw,
 		httpClient: &http.Client{
 			Transport: transport,
-			Timeout:   5 * time.Second,
 		},
-		noEscape: cc.NoEscape,
+		noEscape:       cc.NoEscape,
+		parallelism:    parallelism,
+		retryPolicy:    retryPolicy,
+		requestTimeout: requestTimeout,
+		recorder:       cc.Recorder,
 	}, nil
 }
 
 func (c *redfishClient) Traverse(ctx context.Context, rule *CollectRule) Collected {
-	cl := Collected{data: make(map[string]*gabs.Container), rule: rule}
-	c.get(ctx, rule.TraverseRule.Root, cl)
-	return cl
+	// Creating a session is much cheaper for BMC than authenticating every request.
+	// If the BMC does not support sessions, fall back to basic authentication.
+	s, err := c.openSession(ctx)
+	if err != nil {
+		log.Warn("failed to create Redfish session; use basic authentication instead", map[string]interface{}{
+			log.FnError: err,
+		})
+	}
+
+	t := &traversal{
+		client:  c,
+		rule:    rule,
+		session: s,
+		sem:     make(chan struct{}, c.parallelism),
+		visited: make(map[string]bool),
+		data:    make(map[string]*gabs.Container),
+		stats:   new(statsRecorder),
+	}
+	t.get(ctx, rule.TraverseRule.Root)
+	t.wg.Wait()
+
+	// The session may have been renewed during the traversal.
+	if s := t.currentSession(); s != nil {
+		c.closeSession(s)
+	}
+
+	return Collected{data: t.data, rule: rule, stats: t.stats.get()}
+}
+
+func (c *redfishClient) openSession(ctx context.Context) (*session, error) {
+	body, err := json.Marshal(map[string]string{
+		"UserName": c.user,
+		"Password": c.password,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, sessionsPath, bytes.NewReader(body), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%d: %s", resp.StatusCode, req.URL.String())
+	}
+
+	token := resp.Header.Get("X-Auth-Token")
+	if token == "" {
+		return nil, errors.New("X-Auth-Token was not returned")
+	}
+
+	return &session{
+		token:    token,
+		location: resp.Header.Get("Location"),
+	}, nil
+}
+
+// closeSession deletes the session.
+// This does not take the context of the traversal because the session should be
+// deleted even if the traversal is canceled; BMC can hold only a few sessions.
+func (c *redfishClient) closeSession(s *session) {
+	if s.location == "" {
+		log.Warn("cannot delete Redfish session without location", nil)
+		return
+	}
+
+	// Location may be an absolute URL or a path.
+	u, err := url.Parse(s.location)
+	if err != nil {
+		log.Warn("failed to parse location of Redfish session", map[string]interface{}{
+			"location":  s.location,
+			log.FnError: err,
+		})
+		return
+	}
+
+	req, err := c.newRequest(context.Background(), http.MethodDelete, u.Path, nil, s)
+	if err != nil {
+		log.Warn("failed to create request", map[string]interface{}{
+			"path":      u.Path,
+			log.FnError: err,
+		})
+		return
+	}
+
+	resp, _, err := c.do(req)
+	if err != nil {
+		log.Warn("failed to delete Redfish session", map[string]interface{}{
+			"url":       req.URL.String(),
+			log.FnError: err,
+		})
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		log.Warn("Redfish answered non-OK for session deletion", map[string]interface{}{
+			"url":    req.URL.String(),
+			"status": resp.StatusCode,
+		})
+	}
 }
 
 func (c *redfishClient) GetVersion(ctx context.Context) (string, error) {
-	req, err := c.newRequest(ctx, "/redfish/v1/")
+	req, err := c.newRequest(ctx, http.MethodGet, "/redfish/v1/", nil, nil)
 	if err != nil {
 		panic(err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, body, err := c.do(req)
 	if err != nil {
 		log.Warn("failed to GET Redfish data", map[string]interface{}{
 			"url":       req.URL.String(),
@@ -81,7 +260,6 @@ func (c *redfishClient) GetVersion(ctx context.Context) (string, error) {
 		})
 		return "", err
 	}
-	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		log.Warn("Redfish answered non-OK", map[string]interface{}{
 			"url":       req.URL.String(),
@@ -94,103 +272,381 @@ func (c *redfishClient) GetVersion(ctx context.Context) (string, error) {
 	var result struct {
 		RedfishVersion string
 	}
-	err = json.NewDecoder(resp.Body).Decode(&result)
+	err = json.Unmarshal(body, &result)
 
 	return result.RedfishVersion, err
 }
 
-func (c *redfishClient) get(ctx context.Context, path string, cl Collected) {
-	if !cl.rule.TraverseRule.NeedTraverse(path) {
+// traversal holds the state of a single Traverse call.
+// Pages are fetched concurrently, and sem limits the number of in-flight requests.
+type traversal struct {
+	client *redfishClient
+	rule   *CollectRule
+	sem    chan struct{}
+	wg     sync.WaitGroup
+	stats  *statsRecorder
+
+	sessionMu sync.Mutex
+	session   *session
+	renewed   bool
+
+	mu      sync.Mutex
+	visited map[string]bool
+	data    map[string]*gabs.Container
+}
+
+func (t *traversal) currentSession() *session {
+	t.sessionMu.Lock()
+	defer t.sessionMu.Unlock()
+	return t.session
+}
+
+// renewSession opens a new session in place of s, which BMC has rejected, e.g. because it expired.
+// The session is renewed at most once per traversal so that a BMC rejecting every session is not
+// flooded with new sessions.  It returns nil if the session cannot be renewed.
+// The rejected session is not deleted because BMC no longer accepts it.
+func (t *traversal) renewSession(ctx context.Context, s *session) *session {
+	t.sessionMu.Lock()
+	defer t.sessionMu.Unlock()
+
+	if t.session != s {
+		// Another fetch has already renewed the session.
+		return t.session
+	}
+	if t.renewed {
+		return nil
+	}
+	t.renewed = true
+
+	ns, err := t.client.openSession(ctx)
+	if err != nil {
+		log.Warn("failed to re-create Redfish session", map[string]interface{}{
+			log.FnError: err,
+		})
+		return nil
+	}
+	log.Info("re-created Redfish session", nil)
+	t.session = ns
+	return ns
+}
+
+// statsRecorder accumulates TraverseStats from concurrent fetches.
+// Methods of a nil statsRecorder do nothing.
+type statsRecorder struct {
+	mu    sync.Mutex
+	stats TraverseStats
+}
+
+func (r *statsRecorder) add(f func(*TraverseStats)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f(&r.stats)
+}
+
+func (r *statsRecorder) response(code int) {
+	r.add(func(s *TraverseStats) {
+		if s.StatusCodes == nil {
+			s.StatusCodes = make(map[int]int)
+		}
+		s.StatusCodes[code]++
+	})
+}
+
+func (r *statsRecorder) get() TraverseStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// get starts fetching the page at path in background unless it is excluded or already visited.
+func (t *traversal) get(ctx context.Context, path string) {
+	t.mu.Lock()
+	if t.visited[path] {
+		t.mu.Unlock()
 		return
 	}
+	t.visited[path] = true
+	t.mu.Unlock()
 
-	if _, ok := cl.data[path]; ok {
+	if !t.rule.TraverseRule.NeedTraverse(path) {
+		t.stats.add(func(s *TraverseStats) { s.Excluded++ })
 		return
 	}
 
-	req, err := c.newRequest(ctx, path)
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+
+		select {
+		case t.sem <- struct{}{}:
+		case <-ctx.Done():
+			t.stats.add(func(s *TraverseStats) { s.Failed++ })
+			return
+		}
+		parsed := t.client.fetch(ctx, path, t.currentSession(), t.renewSession, t.stats)
+		<-t.sem
+
+		if parsed == nil {
+			return
+		}
+		t.fetchNextPages(ctx, path, parsed)
+		t.mu.Lock()
+		t.data[path] = parsed
+		t.mu.Unlock()
+
+		t.follow(ctx, parsed)
+	}()
+}
+
+// fetchNextPages follows Members@odata.nextLink of the collection at path, and
+// appends the members of the following pages to parsed as one collection.
+func (t *traversal) fetchNextPages(ctx context.Context, path string, parsed *gabs.Container) {
+	collection, ok := parsed.Data().(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	MergeCollectionPages(path, collection, func(link string) (map[string]interface{}, bool) {
+		select {
+		case t.sem <- struct{}{}:
+		case <-ctx.Done():
+			t.stats.add(func(s *TraverseStats) { s.Failed++ })
+			return nil, false
+		}
+		next := t.client.fetch(ctx, link, t.currentSession(), t.renewSession, t.stats)
+		<-t.sem
+
+		if next == nil {
+			return nil, false
+		}
+		page, ok := next.Data().(map[string]interface{})
+		return page, ok
+	})
+}
+
+// MergeCollectionPages follows Members@odata.nextLink of the collection at path, and
+// appends the members of the following pages returned by next to collection.
+// Absolute links are passed to next as paths with query strings.
+// It stops when next returns false, at a link already seen, or after maxCollectionPages
+// pages, and always removes Members@odata.nextLink from collection.
+// It returns the links of the merged pages.
+func MergeCollectionPages(path string, collection map[string]interface{}, next func(link string) (map[string]interface{}, bool)) []string {
+	defer delete(collection, nextLinkKey)
+
+	var merged []string
+	seen := map[string]bool{path: true}
+	page := collection
+	for pages := 1; ; pages++ {
+		link, ok := NextLink(page)
+		if !ok {
+			return merged
+		}
+		if seen[link] || pages >= maxCollectionPages {
+			log.Warn("stop following Members@odata.nextLink", map[string]interface{}{
+				"path":  path,
+				"next":  link,
+				"pages": pages,
+			})
+			return merged
+		}
+		seen[link] = true
+
+		page, ok = next(link)
+		if !ok {
+			return merged
+		}
+		members, _ := collection["Members"].([]interface{})
+		nextMembers, _ := page["Members"].([]interface{})
+		collection["Members"] = append(members, nextMembers...)
+		merged = append(merged, link)
+	}
+}
+
+// NextLink returns Members@odata.nextLink of page as a path with a query string.
+func NextLink(page map[string]interface{}) (string, bool) {
+	link, ok := page[nextLinkKey].(string)
+	if !ok {
+		return "", false
+	}
+	// nextLink may be an absolute URL.
+	if u, err := url.Parse(link); err == nil && u.Host != "" {
+		link = u.RequestURI()
+	}
+	return link, true
+}
+
+func (t *traversal) follow(ctx context.Context, parsed *gabs.Container) {
+	if childrenMap, err := parsed.ChildrenMap(); err == nil {
+		for k, v := range childrenMap {
+			if k != "@odata.id" {
+				t.follow(ctx, v)
+			} else if path, ok := v.Data().(string); ok {
+				t.get(ctx, path)
+			} else {
+				log.Warn("value of @odata.id is not string", map[string]interface{}{
+					"typ":   reflect.TypeOf(v.Data()),
+					"value": v.Data(),
+				})
+			}
+		}
+		return
+	}
+
+	if childrenSlice, err := parsed.Children(); err == nil {
+		for _, v := range childrenSlice {
+			t.follow(ctx, v)
+		}
+		return
+	}
+}
+
+// fetch gets and parses the page at path, retrying according to the retry policy.
+// If BMC rejects the session s, renew is called to get a new session, and the page is fetched
+// again with it unless renew returns nil.
+// It returns nil on failure.  The result is recorded in stats if it is not nil.
+func (c *redfishClient) fetch(ctx context.Context, path string, s *session, renew func(context.Context, *session) *session, stats *statsRecorder) *gabs.Container {
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil, s)
 	if err != nil {
 		log.Warn("failed to create request", map[string]interface{}{
 			"path":      path,
 			log.FnError: err,
 		})
-		return
+		stats.add(func(s *TraverseStats) { s.Failed++ })
+		return nil
+	}
+
+	resp, body, err := c.getWithRetry(ctx, req, stats)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && s != nil && renew != nil {
+		if ns := renew(ctx, s); ns != nil {
+			log.Info("retrying to GET Redfish data with new session", map[string]interface{}{
+				"url": req.URL.String(),
+			})
+			req, err = c.newRequest(ctx, http.MethodGet, path, nil, ns)
+			if err == nil {
+				resp, body, err = c.getWithRetry(ctx, req, stats)
+			}
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		stats.add(func(s *TraverseStats) { s.Failed++ })
+		return nil
 	}
 
-	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		log.Warn("failed to GET Redfish data", map[string]interface{}{
 			"url":       req.URL.String(),
 			log.FnError: err,
 		})
-		return
+		stats.add(func(s *TraverseStats) { s.Failed++ })
+		return nil
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		log.Warn("Redfish answered non-OK", map[string]interface{}{
-			"url":       req.URL.String(),
-			"status":    resp.StatusCode,
-			log.FnError: err,
+			"url":    req.URL.String(),
+			"status": resp.StatusCode,
 		})
-		return
+		stats.add(func(s *TraverseStats) { s.Failed++ })
+		return nil
 	}
 
-	parsed, err := gabs.ParseJSONBuffer(resp.Body)
+	parsed, err := gabs.ParseJSON(body)
 	if err != nil {
 		log.Warn("failed to parse Redfish data", map[string]interface{}{
 			"url":       req.URL.String(),
 			log.FnError: err,
 		})
-		return
+		stats.add(func(s *TraverseStats) { s.ParseErrors++ })
+		return nil
 	}
-	cl.data[path] = parsed
+	stats.add(func(s *TraverseStats) { s.Fetched++ })
+	return parsed
+}
+
+// getWithRetry sends req, retrying according to the retry policy.
+// It returns the error of the context if it is canceled while waiting for a retry.
+func (c *redfishClient) getWithRetry(ctx context.Context, req *http.Request, stats *statsRecorder) (*http.Response, []byte, error) {
+	for attempt := 1; ; attempt++ {
+		resp, body, err := c.do(req)
+		if err == nil {
+			stats.response(resp.StatusCode)
+		}
+		if attempt >= c.retryPolicy.MaxAttempts || !c.retryPolicy.retryable(resp, err) {
+			return resp, body, err
+		}
+
+		wait := c.retryPolicy.backoff(attempt, resp, time.Now())
+		fields := map[string]interface{}{
+			"url":     req.URL.String(),
+			"attempt": attempt,
+			"wait":    wait.String(),
+		}
+		if err != nil {
+			fields[log.FnError] = err
+		} else {
+			fields["status"] = resp.StatusCode
+		}
+		log.Info("retrying to GET Redfish data", fields)
 
-	c.follow(ctx, parsed, cl)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
 }
 
-func (c *redfishClient) newRequest(ctx context.Context, path string) (*http.Request, error) {
-	p := path
+// do sends req and reads the whole response body within the request timeout.
+// The request and its response are recorded if the client has a recorder.
+func (c *redfishClient) do(req *http.Request) (*http.Response, []byte, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.requestTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		c.record(req, start, nil, nil, err)
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	c.record(req, start, resp, body, err)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// newRequest creates a request authenticated with s, or with basic authentication if s is nil.
+func (c *redfishClient) newRequest(ctx context.Context, method, path string, body io.Reader, s *session) (*http.Request, error) {
+	// The query string, e.g. "?$skip=50" in nextLink, must not be escaped.
+	p, query := path, ""
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		p, query = path[:i], path[i:]
+	}
 	if !c.noEscape {
 		p = url.PathEscape(p)
 	}
-	u, err := c.endpoint.Parse(p)
+	u, err := c.endpoint.Parse(p + query)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequest(method, u.String(), body)
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.user, c.password)
+	if s != nil {
+		req.Header.Set("X-Auth-Token", s.token)
+	} else {
+		req.SetBasicAuth(c.user, c.password)
+	}
 	req.Header.Set("Accept", "application/json")
 	req = req.WithContext(ctx)
 
 	return req, nil
 }
-
-func (c *redfishClient) follow(ctx context.Context, parsed *gabs.Container, cl Collected) {
-	if childrenMap, err := parsed.ChildrenMap(); err == nil {
-		for k, v := range childrenMap {
-			if k != "@odata.id" {
-				c.follow(ctx, v, cl)
-			} else if path, ok := v.Data().(string); ok {
-				c.get(ctx, path, cl)
-			} else {
-				log.Warn("value of @odata.id is not string", map[string]interface{}{
-					"typ":   reflect.TypeOf(v.Data()),
-					"value": v.Data(),
-				})
-			}
-		}
-		return
-	}
-
-	if childrenSlice, err := parsed.Children(); err == nil {
-		for _, v := range childrenSlice {
-			c.follow(ctx, v, cl)
-		}
-		return
-	}
-}
@@ -0,0 +1,607 @@
+package redfish
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cybozu-go/setup-hw/config"
+	"github.com/cybozu-go/setup-hw/fakebmc"
+)
+
+const (
+	testUser     = "support"
+	testPassword = "secret"
+	testToken    = "0123456789abcdef"
+)
+
+// fakeSessionBMC is a minimal BMC which serves the chassis page and optionally supports sessions.
+// The n-th session has the token testToken + n, and only the latest session is accepted.
+type fakeSessionBMC struct {
+	supportSession bool
+
+	// expiredSessions is the number of sessions which expire as soon as they are created.
+	expiredSessions int
+
+	mu        sync.Mutex
+	created   int
+	deleted   int
+	basicAuth int
+	tokenAuth int
+}
+
+func (b *fakeSessionBMC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == sessionsPath:
+		if !b.supportSession {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			UserName string
+			Password string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.UserName != testUser || body.Password != testPassword {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		b.created++
+		w.Header().Set("X-Auth-Token", b.token())
+		w.Header().Set("Location", sessionsPath+"/"+strconv.Itoa(b.created))
+		w.WriteHeader(http.StatusCreated)
+		return
+
+	case r.Method == http.MethodDelete && r.URL.Path == sessionsPath+"/"+strconv.Itoa(b.created):
+		if r.Header.Get("X-Auth-Token") != b.token() || b.created <= b.expiredSessions {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		b.deleted++
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if token := r.Header.Get("X-Auth-Token"); token != "" {
+		if token != b.token() || b.created <= b.expiredSessions {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		b.tokenAuth++
+	} else {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != testUser || password != testPassword {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		b.basicAuth++
+	}
+
+	if r.URL.Path != "/redfish/v1/Chassis/System.Embedded.1" {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, "../testdata/redfish_chassis.json")
+}
+
+func (b *fakeSessionBMC) token() string {
+	return testToken + strconv.Itoa(b.created)
+}
+
+func testClientConfig(t *testing.T, ts *httptest.Server) *ClientConfig {
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostAndPort := strings.Split(u.Host, ":")
+	if len(hostAndPort) != 2 {
+		t.Fatal("httptest.NewTLSServer() returned URL with host and/or port omitted")
+	}
+
+	cc, err := clientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.AddressConfig = &config.AddressConfig{IPv4: config.IPv4Config{Address: hostAndPort[0]}}
+	cc.Port = hostAndPort[1]
+	cc.NoEscape = true
+	cc.UserConfig = &config.UserConfig{
+		Support: config.Credentials{Password: config.BMCPassword{Raw: testPassword}},
+	}
+	return cc
+}
+
+func TestNewRedfishClientEndpoint(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		address  string
+		port     string
+		expected string
+	}{
+		{address: "10.0.0.1", expected: "10.0.0.1"},
+		{address: "10.0.0.1", port: "8443", expected: "10.0.0.1:8443"},
+		{address: "fd00::1", expected: "[fd00::1]"},
+		{address: "fd00::1", port: "443", expected: "[fd00::1]:443"},
+		{address: "[fd00::1]", expected: "[fd00::1]"},
+		{address: "[fd00::1]", port: "443", expected: "[fd00::1]:443"},
+	}
+
+	for _, tc := range testcases {
+		cc, err := clientConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cc.AddressConfig = &config.AddressConfig{IPv4: config.IPv4Config{Address: tc.address}}
+		cc.Port = tc.port
+
+		client, err := NewRedfishClient(cc)
+		if err != nil {
+			t.Errorf("%s port %q: unexpected error: %v", tc.address, tc.port, err)
+			continue
+		}
+		if host := client.(*redfishClient).endpoint.Host; host != tc.expected {
+			t.Errorf("%s port %q: expected host %q, actual %q", tc.address, tc.port, tc.expected, host)
+		}
+	}
+}
+
+func TestSessionAuth(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name           string
+		supportSession bool
+	}{
+		{
+			name:           "session",
+			supportSession: true,
+		},
+		{
+			name:           "basic",
+			supportSession: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bmc := &fakeSessionBMC{supportSession: tc.supportSession}
+			ts := httptest.NewTLSServer(bmc)
+			defer ts.Close()
+
+			cc := testClientConfig(t, ts)
+			client, err := NewRedfishClient(cc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cl := client.Traverse(context.Background(), cc.Rule)
+			if _, ok := cl.Data()["/redfish/v1/Chassis/System.Embedded.1"]; !ok {
+				t.Fatal("root page was not traversed")
+			}
+
+			bmc.mu.Lock()
+			defer bmc.mu.Unlock()
+			if tc.supportSession {
+				if bmc.created != 1 || bmc.deleted != 1 {
+					t.Error("session was not created and deleted exactly once; created:", bmc.created, "deleted:", bmc.deleted)
+				}
+				if bmc.basicAuth != 0 || bmc.tokenAuth == 0 {
+					t.Error("pages were not fetched with the session; basic:", bmc.basicAuth, "token:", bmc.tokenAuth)
+				}
+			} else {
+				if bmc.basicAuth == 0 || bmc.tokenAuth != 0 {
+					t.Error("pages were not fetched with basic authentication; basic:", bmc.basicAuth, "token:", bmc.tokenAuth)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionRenewal(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name            string
+		expiredSessions int
+		traversed       bool
+		deleted         int
+	}{
+		{
+			name:            "renewed",
+			expiredSessions: 1,
+			traversed:       true,
+			deleted:         1,
+		},
+		{
+			name:            "renewed only once",
+			expiredSessions: 2,
+			traversed:       false,
+			deleted:         0,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bmc := &fakeSessionBMC{supportSession: true, expiredSessions: tc.expiredSessions}
+			ts := httptest.NewTLSServer(bmc)
+			defer ts.Close()
+
+			cc := testClientConfig(t, ts)
+			client, err := NewRedfishClient(cc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cl := client.Traverse(context.Background(), cc.Rule)
+			if _, ok := cl.Data()["/redfish/v1/Chassis/System.Embedded.1"]; ok != tc.traversed {
+				t.Error("unexpected result of traversal:", ok)
+			}
+			if stats := cl.Stats(); stats.StatusCodes[http.StatusUnauthorized] != tc.expiredSessions {
+				t.Error("unexpected number of rejected requests:", stats.StatusCodes)
+			}
+
+			bmc.mu.Lock()
+			defer bmc.mu.Unlock()
+			if bmc.created != 2 || bmc.deleted != tc.deleted {
+				t.Error("unexpected number of sessions; created:", bmc.created, "deleted:", bmc.deleted)
+			}
+			if bmc.basicAuth != 0 {
+				t.Error("basic authentication was used:", bmc.basicAuth)
+			}
+		})
+	}
+}
+
+// treeBMC serves a tree of pages with cross references, counting concurrent requests.
+type treeBMC struct {
+	inFlight    int32
+	maxInFlight int32
+}
+
+func (b *treeBMC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := atomic.AddInt32(&b.inFlight, 1)
+	defer atomic.AddInt32(&b.inFlight, -1)
+	for {
+		cur := atomic.LoadInt32(&b.maxInFlight)
+		if n <= cur || atomic.CompareAndSwapInt32(&b.maxInFlight, cur, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	const items = 20
+	link := func(path string) map[string]interface{} {
+		return map[string]interface{}{"@odata.id": path}
+	}
+
+	var page map[string]interface{}
+	var i, j int
+	switch {
+	case r.URL.Path == "/redfish/v1":
+		var members []interface{}
+		for i := 0; i < items; i++ {
+			members = append(members, link(fmt.Sprintf("/redfish/v1/Items/%d", i)))
+		}
+		page = map[string]interface{}{"Members": members}
+	case strings.HasPrefix(r.URL.Path, "/redfish/v1/Items/") && strings.Contains(r.URL.Path, "/Sub/"):
+		if _, err := fmt.Sscanf(r.URL.Path, "/redfish/v1/Items/%d/Sub/%d", &i, &j); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		page = map[string]interface{}{
+			"Value":   i*10 + j,
+			"Sibling": link(fmt.Sprintf("/redfish/v1/Items/%d", (i+1)%items)),
+		}
+	default:
+		if _, err := fmt.Sscanf(r.URL.Path, "/redfish/v1/Items/%d", &i); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		page = map[string]interface{}{
+			"Parent": link("/redfish/v1"),
+			"Subs": []interface{}{
+				link(fmt.Sprintf("/redfish/v1/Items/%d/Sub/0", i)),
+				link(fmt.Sprintf("/redfish/v1/Items/%d/Sub/1", i)),
+			},
+		}
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+func TestTraverseConcurrently(t *testing.T) {
+	t.Parallel()
+
+	rule := &CollectRule{
+		TraverseRule: TraverseRule{
+			Root: "/redfish/v1",
+		},
+	}
+	if err := rule.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	traverse := func(parallelism int) (Collected, int32) {
+		bmc := &treeBMC{}
+		ts := httptest.NewTLSServer(bmc)
+		defer ts.Close()
+
+		cc := testClientConfig(t, ts)
+		cc.Parallelism = parallelism
+		client, err := NewRedfishClient(cc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client.Traverse(context.Background(), rule), atomic.LoadInt32(&bmc.maxInFlight)
+	}
+
+	sequential, maxSequential := traverse(1)
+	concurrent, maxConcurrent := traverse(8)
+
+	if len(sequential.Data()) != 1+20+40 {
+		t.Error("wrong number of pages were traversed:", len(sequential.Data()))
+	}
+	if maxSequential != 1 {
+		t.Error("sequential traversal sent concurrent requests:", maxSequential)
+	}
+	if maxConcurrent < 2 || maxConcurrent > 8 {
+		t.Error("concurrent traversal did not respect the limit:", maxConcurrent)
+	}
+
+	if len(concurrent.Data()) != len(sequential.Data()) {
+		t.Error("concurrent traversal returned different number of pages; expected:", len(sequential.Data()), "actual:", len(concurrent.Data()))
+	}
+	for path, expected := range sequential.Data() {
+		actual, ok := concurrent.Data()[path]
+		if !ok {
+			t.Error("path was not traversed concurrently:", path)
+			continue
+		}
+		if actual.String() != expected.String() {
+			t.Error("wrong contents were loaded:", path, "\nexpected:", expected.String(), "\nactual:", actual.String())
+		}
+	}
+}
+
+func TestTraverseFakeBMC(t *testing.T) {
+	t.Parallel()
+
+	pages, err := fakebmc.LoadDump("../testdata/fakebmc_dump.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule := &CollectRule{
+		TraverseRule: TraverseRule{
+			Root:         "/redfish/v1",
+			ExcludeRules: []string{"/SessionService"},
+		},
+	}
+	if err := rule.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	newClient := func(t *testing.T, bmc *fakebmc.Server) Client {
+		ts := httptest.NewTLSServer(bmc)
+		t.Cleanup(ts.Close)
+
+		cc := testClientConfig(t, ts)
+		cc.RetryPolicy = &RetryPolicy{
+			MaxAttempts:          2,
+			InitialBackoff:       time.Millisecond,
+			MaxBackoff:           time.Millisecond,
+			RetryableStatusCodes: []int{http.StatusInternalServerError},
+		}
+		client, err := NewRedfishClient(cc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	t.Run("normal", func(t *testing.T) {
+		t.Parallel()
+
+		bmc := fakebmc.New(pages, fakebmc.Config{User: testUser, Password: testPassword})
+		client := newClient(t, bmc)
+
+		version, err := client.GetVersion(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != "1.6.0" {
+			t.Error("wrong version:", version)
+		}
+
+		cl := client.Traverse(context.Background(), rule)
+		if len(cl.Data()) != len(pages) {
+			t.Error("wrong number of pages were traversed:", len(cl.Data()))
+		}
+		// The path contains a colon, which must not be escaped.
+		if _, ok := cl.Data()["/redfish/v1/Chassis/Enclosure.Internal.0-1:RAID.Slot.1-1"]; !ok {
+			t.Error("page with a colon in its path was not traversed")
+		}
+		if n := bmc.Sessions(); n != 0 {
+			t.Error("session was not deleted:", n)
+		}
+	})
+
+	t.Run("faults", func(t *testing.T) {
+		t.Parallel()
+
+		bmc := fakebmc.New(pages, fakebmc.Config{User: testUser, Password: testPassword})
+		bmc.SetFault("/redfish/v1/Chassis", fakebmc.Fault{Status: http.StatusInternalServerError, Count: 1})
+		bmc.SetFault("/redfish/v1/Chassis/System.Embedded.1", fakebmc.Fault{Malformed: true})
+		client := newClient(t, bmc)
+
+		cl := client.Traverse(context.Background(), rule)
+		stats := cl.Stats()
+		if stats.Fetched != len(pages)-1 || stats.ParseErrors != 1 {
+			t.Errorf("unexpected stats: %+v", stats)
+		}
+		if n := bmc.Requests("/redfish/v1/Chassis"); n != 2 {
+			t.Error("failed request was not retried once:", n)
+		}
+		if stats.StatusCodes[http.StatusInternalServerError] != 1 {
+			t.Errorf("500 was not counted: %+v", stats.StatusCodes)
+		}
+	})
+
+	t.Run("record", func(t *testing.T) {
+		t.Parallel()
+
+		bmc := fakebmc.New(pages, fakebmc.Config{User: testUser, Password: testPassword})
+		bmc.SetFault("/redfish/v1/Chassis", fakebmc.Fault{Status: http.StatusInternalServerError, Count: 1})
+		ts := httptest.NewTLSServer(bmc)
+		t.Cleanup(ts.Close)
+
+		var mu sync.Mutex
+		methods := make(map[string]int)
+		statuses := make(map[string][]int)
+		cc := testClientConfig(t, ts)
+		cc.RetryPolicy = &RetryPolicy{
+			MaxAttempts:          2,
+			InitialBackoff:       time.Millisecond,
+			MaxBackoff:           time.Millisecond,
+			RetryableStatusCodes: []int{http.StatusInternalServerError},
+		}
+		cc.Recorder = func(ex *Exchange) {
+			mu.Lock()
+			defer mu.Unlock()
+			methods[ex.Method]++
+			u, err := url.Parse(ex.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			statuses[u.Path] = append(statuses[u.Path], ex.Status)
+			if ex.Header.Get("X-Auth-Token") != "" {
+				t.Error("X-Auth-Token was recorded:", ex.URL)
+			}
+			if ex.Status == http.StatusOK && len(ex.Body) == 0 {
+				t.Error("body was not recorded:", ex.URL)
+			}
+		}
+		client, err := NewRedfishClient(cc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client.Traverse(context.Background(), rule)
+		mu.Lock()
+		defer mu.Unlock()
+		if methods[http.MethodPost] != 1 || methods[http.MethodDelete] != 1 || methods[http.MethodGet] != len(pages)+1 {
+			t.Errorf("unexpected requests were recorded: %v", methods)
+		}
+		if s := statuses["/redfish/v1/Chassis"]; len(s) != 2 || s[0] != http.StatusInternalServerError || s[1] != http.StatusOK {
+			t.Error("retried request was not recorded:", statuses["/redfish/v1/Chassis"])
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		t.Parallel()
+
+		bmc := fakebmc.New(pages, fakebmc.Config{User: testUser, Password: "another"})
+		client := newClient(t, bmc)
+
+		cl := client.Traverse(context.Background(), rule)
+		if len(cl.Data()) != 0 {
+			t.Error("pages were traversed without valid credentials:", len(cl.Data()))
+		}
+		if cl.Stats().StatusCodes[http.StatusUnauthorized] != 1 {
+			t.Errorf("401 was not returned: %+v", cl.Stats().StatusCodes)
+		}
+	})
+}
+
+func TestTraverseNextLink(t *testing.T) {
+	t.Parallel()
+
+	pages := map[string]json.RawMessage{
+		"/redfish/v1": json.RawMessage(`{"Systems": {"@odata.id": "/redfish/v1/Systems"}}`),
+		"/redfish/v1/Systems": json.RawMessage(`{
+			"Members": [{"@odata.id": "/redfish/v1/Systems/1"}],
+			"Members@odata.count": 3,
+			"Members@odata.nextLink": "/redfish/v1/Systems?$skip=1"
+		}`),
+		"/redfish/v1/Systems?$skip=1": json.RawMessage(`{
+			"Members": [{"@odata.id": "/redfish/v1/Systems/2"}],
+			"Members@odata.nextLink": "/redfish/v1/Systems?$skip=2"
+		}`),
+		// The last page links to the second page again, which must not loop.
+		"/redfish/v1/Systems?$skip=2": json.RawMessage(`{
+			"Members": [{"@odata.id": "/redfish/v1/Systems/3"}],
+			"Members@odata.nextLink": "/redfish/v1/Systems?$skip=1"
+		}`),
+		"/redfish/v1/Systems/1": json.RawMessage(`{"Id": "1"}`),
+		"/redfish/v1/Systems/2": json.RawMessage(`{"Id": "2"}`),
+		"/redfish/v1/Systems/3": json.RawMessage(`{"Id": "3"}`),
+	}
+
+	bmc := fakebmc.New(pages, fakebmc.Config{User: testUser, Password: testPassword})
+	ts := httptest.NewTLSServer(bmc)
+	defer ts.Close()
+
+	client, err := NewRedfishClient(testClientConfig(t, ts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule := &CollectRule{
+		TraverseRule: TraverseRule{
+			Root: "/redfish/v1",
+		},
+	}
+	if err := rule.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	cl := client.Traverse(context.Background(), rule)
+
+	systems, ok := cl.Data()["/redfish/v1/Systems"]
+	if !ok {
+		t.Fatal("collection was not traversed")
+	}
+	members, err := systems.Path("Members").Children()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 {
+		t.Error("pages of the collection were not merged:", systems.String())
+	}
+	if systems.Exists(nextLinkKey) {
+		t.Error("nextLink was left in the merged collection:", systems.String())
+	}
+	for _, path := range []string{"/redfish/v1/Systems/1", "/redfish/v1/Systems/2", "/redfish/v1/Systems/3"} {
+		if _, ok := cl.Data()[path]; !ok {
+			t.Error("member was not traversed:", path)
+		}
+	}
+	if _, ok := cl.Data()["/redfish/v1/Systems?$skip=1"]; ok {
+		t.Error("next page was collected as a separate page")
+	}
+	if n := bmc.Requests("/redfish/v1/Systems?$skip=1"); n != 1 {
+		t.Error("next page was requested repeatedly:", n)
+	}
+}
@@ -107,6 +107,18 @@ var Rules = map[string]*CollectRule{
 			{
 				Path: "/redfish/v1/Systems/{system}",
 				PropertyRules: []*PropertyRule{
+					{
+						Pointer: "/Model",
+						Name:    "system_info",
+						Help:    "",
+						Type:    "info",
+						Labels: []string{
+							"Manufacturer",
+							"SerialNumber",
+							"SKU",
+							"BiosVersion",
+						},
+					},
 					{
 						Pointer: "/Status/Health",
 						Name:    "system_status_health",
@@ -175,6 +187,12 @@ var Rules = map[string]*CollectRule{
 			{
 				Path: "/redfish/v1/Managers/{manager}",
 				PropertyRules: []*PropertyRule{
+					{
+						Pointer: "/FirmwareVersion",
+						Name:    "manager_firmware_info",
+						Help:    "",
+						Type:    "info",
+					},
 					{
 						Pointer: "/Status/Health",
 						Name:    "manager_status_health",
@@ -287,6 +305,18 @@ var Rules = map[string]*CollectRule{
 			{
 				Path: "/redfish/v1/Systems/{system}",
 				PropertyRules: []*PropertyRule{
+					{
+						Pointer: "/Model",
+						Name:    "system_info",
+						Help:    "",
+						Type:    "info",
+						Labels: []string{
+							"Manufacturer",
+							"SerialNumber",
+							"SKU",
+							"BiosVersion",
+						},
+					},
 					{
 						Pointer: "/Status/Health",
 						Name:    "system_status_health",
@@ -360,6 +390,12 @@ var Rules = map[string]*CollectRule{
 			{
 				Path: "/redfish/v1/Managers/{manager}",
 				PropertyRules: []*PropertyRule{
+					{
+						Pointer: "/FirmwareVersion",
+						Name:    "manager_firmware_info",
+						Help:    "",
+						Type:    "info",
+					},
 					{
 						Pointer: "/Status/Health",
 						Name:    "manager_status_health",
@@ -551,6 +587,12 @@ var Rules = map[string]*CollectRule{
 			{
 				Path: "/redfish/v1/Managers/{manager}",
 				PropertyRules: []*PropertyRule{
+					{
+						Pointer: "/FirmwareVersion",
+						Name:    "managers_firmware_info",
+						Help:    "",
+						Type:    "info",
+					},
 					{
 						Pointer: "/Status/Health",
 						Name:    "managers_status_health",
@@ -602,6 +644,18 @@ var Rules = map[string]*CollectRule{
 			{
 				Path: "/redfish/v1/Systems/{system}",
 				PropertyRules: []*PropertyRule{
+					{
+						Pointer: "/Model",
+						Name:    "systems_info",
+						Help:    "",
+						Type:    "info",
+						Labels: []string{
+							"Manufacturer",
+							"SerialNumber",
+							"SKU",
+							"BiosVersion",
+						},
+					},
 					{
 						Pointer: "/HostWatchdogTimer/Status/State",
 						Name:    "systems_hostwatchdogtimer_status_state",
@@ -1199,6 +1253,12 @@ var Rules = map[string]*CollectRule{
 			{
 				Path: "/redfish/v1/Managers/{manager}",
 				PropertyRules: []*PropertyRule{
+					{
+						Pointer: "/FirmwareVersion",
+						Name:    "managers_firmware_info",
+						Help:    "",
+						Type:    "info",
+					},
 					{
 						Pointer: "/Status/Health",
 						Name:    "managers_status_health",
@@ -1250,6 +1310,18 @@ var Rules = map[string]*CollectRule{
 			{
 				Path: "/redfish/v1/Systems/{system}",
 				PropertyRules: []*PropertyRule{
+					{
+						Pointer: "/Model",
+						Name:    "systems_info",
+						Help:    "",
+						Type:    "info",
+						Labels: []string{
+							"Manufacturer",
+							"SerialNumber",
+							"SKU",
+							"BiosVersion",
+						},
+					},
 					{
 						Pointer: "/HostWatchdogTimer/Status/State",
 						Name:    "systems_hostwatchdogtimer_status_state",
@@ -0,0 +1,104 @@
+package redfish
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultRequestTimeout is the timeout of a request to Redfish API used when ClientConfig.RequestTimeout is not positive.
+const DefaultRequestTimeout = 5 * time.Second
+
+// RetryPolicy specifies how redfishClient retries failed GET requests.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry.  The wait doubles for every retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between attempts, including the one requested by Retry-After.
+	// Zero means no limit.
+	MaxBackoff time.Duration
+
+	// RetryableStatusCodes is the list of HTTP status codes to be retried.
+	// Transport errors such as timeouts and reset connections are always retried.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy returns the retry policy used when ClientConfig.RetryPolicy is nil.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// retryable returns whether the result of an attempt should be retried.
+// resp is nil if the request failed without a response.
+func (p *RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait after the attempt-th attempt.
+// If resp has a valid Retry-After header, it takes precedence over the exponential backoff.
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response, now time.Time) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if p.MaxBackoff > 0 && wait >= p.MaxBackoff {
+			break
+		}
+	}
+
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			wait = d
+		}
+	}
+
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	return wait
+}
+
+// parseRetryAfter parses the value of Retry-After header, which is either delay-seconds or HTTP-date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if sec, err := strconv.Atoi(value); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	d := t.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
@@ -0,0 +1,179 @@
+package redfish
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	policy := &RetryPolicy{
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     10 * time.Second,
+	}
+
+	testcases := []struct {
+		attempt    int
+		retryAfter string
+		expected   time.Duration
+	}{
+		{attempt: 1, expected: 1 * time.Second},
+		{attempt: 2, expected: 2 * time.Second},
+		{attempt: 3, expected: 4 * time.Second},
+		{attempt: 5, expected: 10 * time.Second},
+		{attempt: 100, expected: 10 * time.Second},
+		{attempt: 1, retryAfter: "3", expected: 3 * time.Second},
+		{attempt: 1, retryAfter: "60", expected: 10 * time.Second},
+		{attempt: 1, retryAfter: now.Add(5 * time.Second).Format(http.TimeFormat), expected: 5 * time.Second},
+		{attempt: 1, retryAfter: now.Add(-5 * time.Second).Format(http.TimeFormat), expected: 0},
+		{attempt: 2, retryAfter: "invalid", expected: 2 * time.Second},
+		{attempt: 2, retryAfter: "-1", expected: 2 * time.Second},
+	}
+
+	for _, tc := range testcases {
+		resp := &http.Response{Header: http.Header{}}
+		if tc.retryAfter != "" {
+			resp.Header.Set("Retry-After", tc.retryAfter)
+		}
+		actual := policy.backoff(tc.attempt, resp, now)
+		if actual != tc.expected {
+			t.Errorf("backoff() returned unexpected result; attempt: %d, Retry-After: %q, expected: %v, actual: %v",
+				tc.attempt, tc.retryAfter, tc.expected, actual)
+		}
+	}
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	t.Parallel()
+
+	policy := DefaultRetryPolicy()
+	if !policy.retryable(nil, errors.New("connection reset by peer")) {
+		t.Error("transport error should be retried")
+	}
+	if !policy.retryable(&http.Response{StatusCode: http.StatusServiceUnavailable}, nil) {
+		t.Error("503 should be retried")
+	}
+	if policy.retryable(&http.Response{StatusCode: http.StatusNotFound}, nil) {
+		t.Error("404 should not be retried")
+	}
+	if policy.retryable(&http.Response{StatusCode: http.StatusOK}, nil) {
+		t.Error("200 should not be retried")
+	}
+}
+
+// flakyBMC fails the first `failures` requests for each path with the given behavior.
+type flakyBMC struct {
+	failures int
+	status   int
+	delay    time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+func (b *flakyBMC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	if b.attempts == nil {
+		b.attempts = make(map[string]int)
+	}
+	b.attempts[r.URL.Path]++
+	n := b.attempts[r.URL.Path]
+	b.mu.Unlock()
+
+	if n <= b.failures {
+		if b.delay > 0 {
+			time.Sleep(b.delay)
+		} else {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "unavailable", b.status)
+			return
+		}
+	}
+
+	if r.URL.Path != "/redfish/v1/Chassis/System.Embedded.1" {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFile(w, r, "../testdata/redfish_chassis.json")
+}
+
+func TestFetchRetry(t *testing.T) {
+	t.Parallel()
+
+	const rootPath = "/redfish/v1/Chassis/System.Embedded.1"
+
+	testcases := []struct {
+		name     string
+		bmc      *flakyBMC
+		success  bool
+		attempts int
+	}{
+		{
+			name:     "recover from 503",
+			bmc:      &flakyBMC{failures: 2, status: http.StatusServiceUnavailable},
+			success:  true,
+			attempts: 3,
+		},
+		{
+			name:     "give up 503",
+			bmc:      &flakyBMC{failures: 3, status: http.StatusServiceUnavailable},
+			success:  false,
+			attempts: 3,
+		},
+		{
+			name:     "do not retry 403",
+			bmc:      &flakyBMC{failures: 1, status: http.StatusForbidden},
+			success:  false,
+			attempts: 1,
+		},
+		{
+			name:     "recover from timeout",
+			bmc:      &flakyBMC{failures: 1, delay: 500 * time.Millisecond},
+			success:  true,
+			attempts: 2,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := httptest.NewTLSServer(tc.bmc)
+			defer ts.Close()
+
+			cc := testClientConfig(t, ts)
+			cc.RetryPolicy = &RetryPolicy{
+				MaxAttempts:          3,
+				InitialBackoff:       10 * time.Millisecond,
+				RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+			}
+			cc.RequestTimeout = 100 * time.Millisecond
+			client, err := NewRedfishClient(cc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed := client.(*redfishClient).fetch(context.Background(), rootPath, nil, nil, nil)
+			if tc.success && parsed == nil {
+				t.Error("fetch() failed")
+			}
+			if !tc.success && parsed != nil {
+				t.Error("fetch() succeeded unexpectedly")
+			}
+
+			tc.bmc.mu.Lock()
+			defer tc.bmc.mu.Unlock()
+			if tc.bmc.attempts[rootPath] != tc.attempts {
+				t.Error("unexpected number of attempts; expected:", tc.attempts, "actual:", tc.bmc.attempts[rootPath])
+			}
+		})
+	}
+}
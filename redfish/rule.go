@@ -3,6 +3,7 @@ package redfish
 import (
 	"context"
 	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
@@ -16,6 +17,7 @@ import (
 type CollectRule struct {
 	TraverseRule TraverseRule  `json:"Traverse"`
 	MetricRules  []*MetricRule `json:"Metrics"`
+	TypeRules    []*TypeRule   `json:"Types,omitempty"`
 }
 
 // RuleGetter is the type to obtain dynamic rules
@@ -28,6 +30,17 @@ type TraverseRule struct {
 	excludeRegexp *regexp.Regexp
 }
 
+// TypeRule defines a type of property which maps enumerated strings to numbers.
+type TypeRule struct {
+	Name    string             `json:"Name"`
+	Values  map[string]float64 `json:"Values"`
+	Default *float64           `json:"Default,omitempty"`
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
 // MetricRule is a set of rules of converting Redfish data for one URL path or patterned-path.
 type MetricRule struct {
 	Path          string          `json:"Path"`
@@ -36,17 +49,25 @@ type MetricRule struct {
 
 // PropertyRule is a rule of converting Redfish data into a Prometheus metric.
 type PropertyRule struct {
-	Pointer   string `json:"Pointer"`
-	Name      string `json:"Name"`
-	Help      string `json:"Help,omitempty"`
-	Type      string `json:"Type"`
+	Pointer   string   `json:"Pointer"`
+	Name      string   `json:"Name"`
+	Help      string   `json:"Help,omitempty"`
+	Type      string   `json:"Type"`
+	Labels    []string `json:"Labels,omitempty"`
 	converter converter
 	desc      *prometheus.Desc
 }
 
+// infoType is the type of a property exported as a constant-1 info metric.
+// The property value and the sibling properties listed in Labels become labels of the metric.
+const infoType = "info"
+
+const infoSuffix = "_info"
+
 type matchedProperty struct {
 	value   float64
 	indexes []string
+	labels  []string
 }
 
 // Validate checks CollectRule and its Descendants.
@@ -55,8 +76,13 @@ func (cr CollectRule) Validate() error {
 		return err
 	}
 
+	converters, err := cr.converters()
+	if err != nil {
+		return err
+	}
+
 	for _, metricRule := range cr.MetricRules {
-		if err := metricRule.validate(); err != nil {
+		if err := metricRule.validate(converters); err != nil {
 			return err
 		}
 	}
@@ -70,8 +96,13 @@ func (cr *CollectRule) Compile() error {
 		return err
 	}
 
+	converters, err := cr.converters()
+	if err != nil {
+		return err
+	}
+
 	for _, metricRule := range cr.MetricRules {
-		if err := metricRule.compile(); err != nil {
+		if err := metricRule.compile(converters); err != nil {
 			return err
 		}
 	}
@@ -79,6 +110,37 @@ func (cr *CollectRule) Compile() error {
 	return nil
 }
 
+// converters returns the converters of the built-in types and the types defined in TypeRules.
+func (cr CollectRule) converters() (map[string]converter, error) {
+	converters := make(map[string]converter)
+	for name, c := range typeToConverters {
+		converters[name] = c
+	}
+
+	for _, typeRule := range cr.TypeRules {
+		if err := typeRule.validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := converters[typeRule.Name]; ok || typeRule.Name == infoType {
+			return nil, errors.New("duplicated type name: " + typeRule.Name)
+		}
+		converters[typeRule.Name] = enumConverter(typeRule.Values, typeRule.Default)
+	}
+
+	return converters, nil
+}
+
+func (tr TypeRule) validate() error {
+	if tr.Name == "" {
+		return errors.New("`Name` is mandatory for type rule")
+	}
+	if len(tr.Values) == 0 {
+		return errors.New("`Values` is mandatory for type rule: " + tr.Name)
+	}
+
+	return nil
+}
+
 func (tr TraverseRule) validate() error {
 	if tr.Root == "" {
 		return errors.New("`Root` is mandatory for traverse rule")
@@ -108,13 +170,18 @@ func (tr *TraverseRule) compile() error {
 	return nil
 }
 
-func (mr MetricRule) validate() error {
+func (mr MetricRule) validate(converters map[string]converter) error {
 	if mr.Path == "" {
 		return errors.New("`Path` is mandatory for metric rule")
 	}
+	for _, elem := range strings.Split(mr.Path, "/") {
+		if _, field, ok := parsePattern(elem); ok && field != "" {
+			return errors.New("field of pattern is not available in `Path`: " + mr.Path)
+		}
+	}
 
 	for _, propertyRule := range mr.PropertyRules {
-		if err := propertyRule.validate(); err != nil {
+		if err := propertyRule.validate(converters); err != nil {
 			return err
 		}
 	}
@@ -122,11 +189,11 @@ func (mr MetricRule) validate() error {
 	return nil
 }
 
-func (mr *MetricRule) compile() error {
+func (mr *MetricRule) compile(converters map[string]converter) error {
 	labelNames := getLabelNamesInPath(mr.Path)
 
 	for _, propertyRule := range mr.PropertyRules {
-		if err := propertyRule.compile(labelNames); err != nil {
+		if err := propertyRule.compile(labelNames, converters); err != nil {
 			return err
 		}
 	}
@@ -134,12 +201,15 @@ func (mr *MetricRule) compile() error {
 	return nil
 }
 
-func (mr MetricRule) matchDataMap(cl Collected) []prometheus.Metric {
+// matchDataMap converts the collected data into metrics.
+// If matches is not nil, it records how each property rule matched into the element of
+// the same index; matches must then be as long as PropertyRules.
+func (mr MetricRule) matchDataMap(cl Collected, matches []PropertyMatch) []prometheus.Metric {
 	var results []prometheus.Metric
 
 	for path, parsedJSON := range cl.data {
 		if matched, pathLabelValues := mr.MatchPath(path); matched {
-			metrics := mr.matchData(parsedJSON, pathLabelValues, path)
+			metrics := mr.matchData(parsedJSON, pathLabelValues, path, matches)
 			results = append(results, metrics...)
 		}
 	}
@@ -168,18 +238,26 @@ func (mr MetricRule) MatchPath(path string) (bool, []string) {
 	return true, labelValues
 }
 
-func (mr MetricRule) matchData(parsedJSON *gabs.Container, pathLabelValues []string, loggedPath string) []prometheus.Metric {
+func (mr MetricRule) matchData(parsedJSON *gabs.Container, pathLabelValues []string, loggedPath string, matches []PropertyMatch) []prometheus.Metric {
 	var results []prometheus.Metric
 
-	for _, propertyRule := range mr.PropertyRules {
-		metrics := propertyRule.matchPointer(parsedJSON, pathLabelValues, loggedPath)
+	for i, propertyRule := range mr.PropertyRules {
+		var pm *PropertyMatch
+		if matches != nil {
+			pm = &matches[i]
+		}
+		metrics := propertyRule.matchPointer(parsedJSON, pathLabelValues, loggedPath, pm)
+		if pm != nil {
+			pm.Pages++
+			pm.Metrics += len(metrics)
+		}
 		results = append(results, metrics...)
 	}
 
 	return results
 }
 
-func (pr PropertyRule) validate() error {
+func (pr PropertyRule) validate(converters map[string]converter) error {
 	if pr.Pointer == "" {
 		return errors.New("`Pointer` is mandatory for property rule")
 	}
@@ -193,29 +271,74 @@ func (pr PropertyRule) validate() error {
 		return errors.New("`Type` is mandatory for property rule")
 	}
 
-	if _, ok := typeToConverters[pr.Type]; !ok {
+	if pr.Type == infoType {
+		if !strings.HasSuffix(pr.Name, infoSuffix) {
+			return fmt.Errorf("`Name` of %s type must end with %q: %s", infoType, infoSuffix, pr.Name)
+		}
+		// The last element names the label of the value, and its parent holds the siblings.
+		elems := strings.Split(pr.Pointer, "/")
+		if _, ok := getLabelName(elems[len(elems)-1]); ok || len(elems) < 2 || elems[len(elems)-1] == "" {
+			return fmt.Errorf("`Pointer` of %s type must end with a property key: %s", infoType, pr.Pointer)
+		}
+		for _, label := range pr.Labels {
+			if label == "" || strings.ContainsAny(label, "/{}") {
+				return errors.New("`Labels` must be keys of sibling properties: " + label)
+			}
+		}
+		return nil
+	}
+
+	if _, ok := converters[pr.Type]; !ok {
 		return errors.New("unknown metric type: " + pr.Type)
 	}
+	if len(pr.Labels) > 0 {
+		return fmt.Errorf("`Labels` is available only for %s type: %s", infoType, pr.Name)
+	}
 
 	return nil
 }
 
-func (pr *PropertyRule) compile(pathLabelNames []string) error {
-	pr.converter = typeToConverters[pr.Type]
+func (pr *PropertyRule) compile(pathLabelNames []string, converters map[string]converter) error {
+	pr.converter = converters[pr.Type]
 
 	labelNames := getLabelNamesInPath(pr.Pointer)
 	allLabelNames := concatenate(pathLabelNames, labelNames)
+	if pr.Type == infoType {
+		allLabelNames = append(allLabelNames, pr.infoLabelNames()...)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range allLabelNames {
+		if seen[name] {
+			return fmt.Errorf("duplicated label name in %s: %s", pr.Name, name)
+		}
+		seen[name] = true
+	}
+
 	pr.desc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", pr.Name), pr.Help, allLabelNames, nil)
 
 	return nil
 }
 
-func (pr PropertyRule) matchPointer(parsedJSON *gabs.Container, pathLabelValues []string, loggedPath string) []prometheus.Metric {
+// infoLabelNames returns the names of the labels holding the property value and the sibling properties.
+// They are the lower-cased keys of the properties.
+func (pr PropertyRule) infoLabelNames() []string {
+	elems := strings.Split(pr.Pointer, "/")
+	names := []string{strings.ToLower(elems[len(elems)-1])}
+	for _, label := range pr.Labels {
+		names = append(names, strings.ToLower(label))
+	}
+	return names
+}
+
+// matchPointer returns the metrics of the property in parsedJSON.
+// Failures are counted in pm if it is not nil.
+func (pr PropertyRule) matchPointer(parsedJSON *gabs.Container, pathLabelValues []string, loggedPath string, pm *PropertyMatch) []prometheus.Metric {
 	var results []prometheus.Metric
 
-	matchedProperties := pr.matchPointerAux(pr.Pointer, parsedJSON, loggedPath)
+	matchedProperties := pr.matchPointerAux(pr.Pointer, parsedJSON, loggedPath, pm)
 	for _, property := range matchedProperties {
-		labelValues := concatenate(pathLabelValues, property.indexes)
+		labelValues := concatenate(concatenate(pathLabelValues, property.indexes), property.labels)
 		m, err := prometheus.NewConstMetric(pr.desc, prometheus.GaugeValue, property.value, labelValues...)
 		if err != nil {
 			log.Warn("failed to create metric", map[string]interface{}{
@@ -225,6 +348,7 @@ func (pr PropertyRule) matchPointer(parsedJSON *gabs.Container, pathLabelValues
 				"value":     property.value,
 				log.FnError: err,
 			})
+			pm.conversionError()
 			continue
 		}
 
@@ -234,8 +358,8 @@ func (pr PropertyRule) matchPointer(parsedJSON *gabs.Container, pathLabelValues
 	return results
 }
 
-func (pr PropertyRule) matchPointerAux(pointer string, parsedJSON *gabs.Container, loggedPath string) []matchedProperty {
-	hasIndexPattern, subPointer, remainder := pr.splitPointer(pointer)
+func (pr PropertyRule) matchPointerAux(pointer string, parsedJSON *gabs.Container, loggedPath string, pm *PropertyMatch) []matchedProperty {
+	hasIndexPattern, field, subPointer, remainder := pr.splitPointer(pointer)
 	if !hasIndexPattern {
 		v := pr.matchPlainPointer(pointer, parsedJSON)
 		if v == nil {
@@ -243,9 +367,14 @@ func (pr PropertyRule) matchPointerAux(pointer string, parsedJSON *gabs.Containe
 				"path":    loggedPath,
 				"pointer": pr.Pointer,
 			})
+			pm.notFound()
 			return nil
 		}
 
+		if pr.Type == infoType {
+			return pr.matchInfo(pointer, v, parsedJSON, loggedPath, pm)
+		}
+
 		value, err := pr.converter(v.Data())
 		if err != nil {
 			log.Warn("failed to interpret Redfish data as metric", map[string]interface{}{
@@ -255,6 +384,7 @@ func (pr PropertyRule) matchPointerAux(pointer string, parsedJSON *gabs.Containe
 				"value":     v.Data(),
 				log.FnError: err,
 			})
+			pm.conversionError()
 			return nil
 		}
 
@@ -272,6 +402,7 @@ func (pr PropertyRule) matchPointerAux(pointer string, parsedJSON *gabs.Containe
 			"path":    loggedPath,
 			"pointer": pr.Pointer,
 		})
+		pm.notFound()
 		return nil
 	}
 
@@ -281,14 +412,17 @@ func (pr PropertyRule) matchPointerAux(pointer string, parsedJSON *gabs.Containe
 			"path":    loggedPath,
 			"pointer": pr.Pointer,
 		})
+		pm.notFound()
 		return nil
 	}
 
+	indexes := pr.elementLabels(field, children, loggedPath)
+
 	var result []matchedProperty
 	for i, child := range children {
-		ms := pr.matchPointerAux(remainder, child, loggedPath)
+		ms := pr.matchPointerAux(remainder, child, loggedPath, pm)
 		for _, m := range ms {
-			m.indexes = append([]string{strconv.Itoa(i)}, m.indexes...)
+			m.indexes = append([]string{indexes[i]}, m.indexes...)
 			result = append(result, m)
 		}
 	}
@@ -296,11 +430,109 @@ func (pr PropertyRule) matchPointerAux(pointer string, parsedJSON *gabs.Containe
 	return result
 }
 
-func (pr PropertyRule) splitPointer(pointer string) (hasIndexPattern bool, subPointer, remainder string) {
+// elementLabels returns the label values of the array elements.
+// If field is given, the value of field in each element is used instead of its index.
+// The index is used for an element without field, and for all elements if the values are not unique.
+func (pr PropertyRule) elementLabels(field string, elements []*gabs.Container, loggedPath string) []string {
+	labels := make([]string, len(elements))
+	for i := range elements {
+		labels[i] = strconv.Itoa(i)
+	}
+	if field == "" {
+		return labels
+	}
+
+	fieldLabels := make([]string, len(elements))
+	seen := make(map[string]bool)
+	for i, element := range elements {
+		fieldLabels[i] = labels[i]
+		v := element.Search(field)
+		if v == nil {
+			continue
+		}
+		value, err := infoLabelValue(v.Data())
+		if err != nil || value == "" {
+			continue
+		}
+		if seen[value] {
+			log.Warn("field values are not unique; falling back to index", map[string]interface{}{
+				"path":    loggedPath,
+				"pointer": pr.Pointer,
+				"field":   field,
+				"value":   value,
+			})
+			return labels
+		}
+		seen[value] = true
+		fieldLabels[i] = value
+	}
+	return fieldLabels
+}
+
+// matchInfo returns an info property whose labels are the value v and its siblings listed in Labels.
+// Absent siblings are labeled with empty strings.
+func (pr PropertyRule) matchInfo(pointer string, v, parsedJSON *gabs.Container, loggedPath string, pm *PropertyMatch) []matchedProperty {
+	value, err := infoLabelValue(v.Data())
+	if err != nil {
+		log.Warn("failed to interpret Redfish data as label", map[string]interface{}{
+			"path":      loggedPath,
+			"pointer":   pr.Pointer,
+			"name":      pr.Name,
+			"value":     v.Data(),
+			log.FnError: err,
+		})
+		pm.conversionError()
+		return nil
+	}
+	labels := []string{value}
+
+	parent := pointer[:strings.LastIndex(pointer, "/")]
+	for _, label := range pr.Labels {
+		var value string
+		if sibling := pr.matchPlainPointer(parent+"/"+label, parsedJSON); sibling != nil {
+			value, err = infoLabelValue(sibling.Data())
+			if err != nil {
+				log.Warn("failed to interpret Redfish data as label", map[string]interface{}{
+					"path":      loggedPath,
+					"pointer":   pr.Pointer,
+					"name":      pr.Name,
+					"label":     label,
+					"value":     sibling.Data(),
+					log.FnError: err,
+				})
+			}
+		}
+		labels = append(labels, value)
+	}
+
+	return []matchedProperty{
+		{
+			value:  1,
+			labels: labels,
+		},
+	}
+}
+
+func infoLabelValue(data interface{}) (string, error) {
+	switch v := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", errors.New("value was not scalar")
+}
+
+func (pr PropertyRule) splitPointer(pointer string) (hasIndexPattern bool, field, subPointer, remainder string) {
 	ts := strings.Split(pointer, "/")
 	for i, t := range ts {
-		if _, ok := getLabelName(t); ok {
+		if _, f, ok := parsePattern(t); ok {
 			hasIndexPattern = true
+			field = f
 			subPointer = strings.Join(ts[0:i], "/")
 			if i != len(ts)-1 {
 				remainder = "/" + strings.Join(ts[i+1:], "/")
@@ -308,7 +540,7 @@ func (pr PropertyRule) splitPointer(pointer string) (hasIndexPattern bool, subPo
 			return
 		}
 	}
-	return false, "", ""
+	return false, "", "", ""
 }
 
 func (pr PropertyRule) matchPlainPointer(pointer string, parsedJSON *gabs.Container) *gabs.Container {
@@ -323,11 +555,25 @@ func concatenate(s, t []string) []string {
 }
 
 func getLabelName(elem string) (string, bool) {
+	name, _, ok := parsePattern(elem)
+	return name, ok
+}
+
+// parsePattern parses a pattern element in the form of `{name}` or `{name:Field}`.
+// Field is the key of the array element whose value is used as the label value instead of the index.
+func parsePattern(elem string) (name, field string, ok bool) {
 	ln := len(elem)
-	if ln >= 3 && elem[0] == '{' && elem[ln-1] == '}' {
-		return elem[1 : ln-1], true
+	if ln < 3 || elem[0] != '{' || elem[ln-1] != '}' {
+		return "", "", false
+	}
+	name = elem[1 : ln-1]
+	if i := strings.Index(name, ":"); i >= 0 {
+		name, field = name[:i], name[i+1:]
+	}
+	if name == "" {
+		return "", "", false
 	}
-	return "", false
+	return name, field, true
 }
 
 func getLabelNamesInPath(path string) []string {
@@ -0,0 +1,268 @@
+package redfish
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cybozu-go/setup-hw/gabs"
+)
+
+func TestPropertyRuleValidateInfo(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name  string
+		rule  PropertyRule
+		valid bool
+	}{
+		{
+			name:  "info",
+			rule:  PropertyRule{Pointer: "/Model", Name: "system_info", Type: "info", Labels: []string{"SerialNumber"}},
+			valid: true,
+		},
+		{
+			name:  "name without suffix",
+			rule:  PropertyRule{Pointer: "/Model", Name: "system_model", Type: "info"},
+			valid: false,
+		},
+		{
+			name:  "label with pointer",
+			rule:  PropertyRule{Pointer: "/Model", Name: "system_info", Type: "info", Labels: []string{"Status/Health"}},
+			valid: false,
+		},
+		{
+			name:  "info in array",
+			rule:  PropertyRule{Pointer: "/Versions/{idx}/Version", Name: "firmware_info", Type: "info"},
+			valid: true,
+		},
+		{
+			name:  "pointer ending with pattern",
+			rule:  PropertyRule{Pointer: "/Versions/{idx}", Name: "firmware_info", Type: "info"},
+			valid: false,
+		},
+		{
+			name:  "pointer ending with slash",
+			rule:  PropertyRule{Pointer: "/", Name: "system_info", Type: "info"},
+			valid: false,
+		},
+		{
+			name:  "labels for non-info type",
+			rule:  PropertyRule{Pointer: "/Status/Health", Name: "system_status_health", Type: "health", Labels: []string{"Name"}},
+			valid: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		err := tc.rule.validate(typeToConverters)
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: invalid rule was accepted", tc.name)
+		}
+	}
+
+	// A rule whose pointer ends with a pattern is rejected before collection.
+	cr := CollectRule{
+		TraverseRule: TraverseRule{Root: "/redfish/v1"},
+		MetricRules: []*MetricRule{
+			{
+				Path: "/redfish/v1/UpdateService",
+				PropertyRules: []*PropertyRule{
+					{Pointer: "/Versions/{idx}", Name: "firmware_info", Type: "info"},
+				},
+			},
+		},
+	}
+	if err := cr.Validate(); err == nil {
+		t.Error("info rule ending with a pattern was accepted")
+	}
+
+	// The label of the value collides with the label of the path.
+	mr := MetricRule{
+		Path: "/redfish/v1/Systems/{model}",
+		PropertyRules: []*PropertyRule{
+			{Pointer: "/Model", Name: "system_info", Type: "info"},
+		},
+	}
+	if err := mr.compile(typeToConverters); err == nil {
+		t.Error("duplicated label name was accepted")
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		elem  string
+		name  string
+		field string
+		ok    bool
+	}{
+		{elem: "{sensor}", name: "sensor", ok: true},
+		{elem: "{sensor:Name}", name: "sensor", field: "Name", ok: true},
+		{elem: "{:Name}", ok: false},
+		{elem: "{}", ok: false},
+		{elem: "Sensors", ok: false},
+	}
+
+	for _, tc := range testcases {
+		name, field, ok := parsePattern(tc.elem)
+		if name != tc.name || field != tc.field || ok != tc.ok {
+			t.Errorf("wrong result for %s: name=%q field=%q ok=%v", tc.elem, name, field, ok)
+		}
+	}
+}
+
+func TestElementLabels(t *testing.T) {
+	t.Parallel()
+
+	parse := func(s string) []*gabs.Container {
+		c, err := gabs.ParseJSON([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		children, err := c.Children()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return children
+	}
+
+	testcases := []struct {
+		name     string
+		field    string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "index",
+			input:    `[{"Name": "CPU1"}, {"Name": "CPU2"}]`,
+			expected: []string{"0", "1"},
+		},
+		{
+			name:     "field",
+			field:    "Name",
+			input:    `[{"Name": "CPU1"}, {"Name": "CPU2"}]`,
+			expected: []string{"CPU1", "CPU2"},
+		},
+		{
+			name:     "partially absent",
+			field:    "MemberId",
+			input:    `[{"MemberId": "PS1"}, {}, {"MemberId": null}]`,
+			expected: []string{"PS1", "1", "2"},
+		},
+		{
+			name:     "not unique",
+			field:    "Name",
+			input:    `[{"Name": "Fan"}, {"Name": "Fan"}]`,
+			expected: []string{"0", "1"},
+		},
+	}
+
+	pr := PropertyRule{Pointer: "/Elements/{element}/Reading"}
+	for _, tc := range testcases {
+		actual := pr.elementLabels(tc.field, parse(tc.input), "/test")
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%s: expected: %v, actual: %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestCollectRuleTypes(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		name  string
+		types []*TypeRule
+		valid bool
+	}{
+		{
+			name: "valid",
+			types: []*TypeRule{
+				{Name: "power_state", Values: map[string]float64{"On": 0, "Off": 1}},
+				{Name: "indicator_led", Values: map[string]float64{"Lit": 0}, Default: floatPtr(-1)},
+			},
+			valid: true,
+		},
+		{
+			name:  "no values",
+			types: []*TypeRule{{Name: "power_state"}},
+			valid: false,
+		},
+		{
+			name:  "built-in name",
+			types: []*TypeRule{{Name: "health", Values: map[string]float64{"OK": 0}}},
+			valid: false,
+		},
+		{
+			name: "duplicated name",
+			types: []*TypeRule{
+				{Name: "power_state", Values: map[string]float64{"On": 0}},
+				{Name: "power_state", Values: map[string]float64{"Off": 1}},
+			},
+			valid: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		rule := CollectRule{
+			TraverseRule: TraverseRule{Root: "/redfish/v1"},
+			TypeRules:    tc.types,
+		}
+		err := rule.Validate()
+		if tc.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: invalid types were accepted", tc.name)
+		}
+	}
+
+	// A property rule must refer to a built-in type or a type defined in the same rule.
+	rule := CollectRule{
+		TraverseRule: TraverseRule{Root: "/redfish/v1"},
+		MetricRules: []*MetricRule{
+			{
+				Path: "/redfish/v1/Systems/{system}",
+				PropertyRules: []*PropertyRule{
+					{Pointer: "/PowerState", Name: "system_power_state", Type: "power_state"},
+				},
+			},
+		},
+	}
+	if err := rule.Validate(); err == nil {
+		t.Error("undefined type was accepted")
+	}
+}
+
+func TestEnumConverter(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]float64{"On": 0, "Off": 1}
+
+	withDefault := enumConverter(values, floatPtr(-2))
+	for _, tc := range []struct {
+		input    interface{}
+		expected float64
+	}{
+		{input: "On", expected: 0},
+		{input: "Off", expected: 1},
+		{input: "Unknown", expected: -2},
+		{input: nil, expected: -2},
+		{input: true, expected: -2},
+	} {
+		actual, err := withDefault(tc.input)
+		if err != nil || actual != tc.expected {
+			t.Errorf("wrong conversion of %v; expected: %g, actual: %g, error: %v", tc.input, tc.expected, actual, err)
+		}
+	}
+
+	withoutDefault := enumConverter(values, nil)
+	if actual, err := withoutDefault("Off"); err != nil || actual != 1 {
+		t.Errorf("wrong conversion of Off; actual: %g, error: %v", actual, err)
+	}
+	if _, err := withoutDefault("Unknown"); err == nil {
+		t.Error("unknown value was converted without default")
+	}
+}